@@ -0,0 +1,49 @@
+package sitemap
+
+// alternatesChecker checks alternate language versions of pages are
+// reciprocal: if page A lists page B as an alternate, page B has to be
+// written and list page A as an alternate too.
+type alternatesChecker struct {
+	// links holds the alternate links in the order they were written
+	links []alternateLink
+	// seen is a set of the written alternate links
+	seen map[alternateLink]struct{}
+}
+
+// alternateLink is a link from a page to its alternate version.
+type alternateLink struct {
+	from, to string
+}
+
+func newAlternatesChecker() *alternatesChecker {
+	return &alternatesChecker{
+		seen: map[alternateLink]struct{}{},
+	}
+}
+
+// add records the alternate links of a written entry.
+func (c *alternatesChecker) add(e *UrlEntry) {
+	for i := range e.Alternates {
+		link := alternateLink{from: e.Loc, to: e.Alternates[i].Href}
+		if link.from == link.to {
+			continue
+		}
+
+		if _, ok := c.seen[link]; !ok {
+			c.seen[link] = struct{}{}
+			c.links = append(c.links, link)
+		}
+	}
+}
+
+// check returns an error describing the first link with no link back.
+func (c *alternatesChecker) check() error {
+	for _, link := range c.links {
+		if _, ok := c.seen[alternateLink{from: link.to, to: link.from}]; !ok {
+			return invalidEntryf("entry %q lists alternate %q which does not "+
+				"list it back", link.from, link.to)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package sitemap
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWriter_CheckAlternates(t *testing.T) {
+	en := "http://goiguide.com/en/"
+	fr := "http://goiguide.com/fr/"
+	de := "http://goiguide.com/de/"
+	alternates := []Alternate{
+		{Hreflang: "en", Href: en},
+		{Hreflang: "fr", Href: fr},
+	}
+
+	t.Run("reciprocal", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := arrayInput{Arr: []UrlEntry{
+			{Loc: en, Alternates: alternates},
+			{Loc: "http://goiguide.com/other/"},
+			{Loc: fr, Alternates: alternates},
+		}}
+		w := Writer{CheckAlternates: true}
+		var out bufferOuput
+
+		Ω(w.WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.index.Len()).ShouldNot(Equal(0))
+	})
+
+	t.Run("acrossFiles", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size: 50_000 + 2,
+			CustomEntry: func(idx int) *UrlEntry {
+				switch idx {
+				case 0:
+					return &UrlEntry{Loc: en, Alternates: alternates}
+				case 50_000 + 1:
+					return &UrlEntry{Loc: fr, Alternates: alternates}
+				default:
+					return &UrlEntry{Loc: "http://goiguide.com/other/"}
+				}
+			},
+		}
+		w := Writer{CheckAlternates: true}
+		var out bufferOuput
+
+		Ω(w.WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(2))
+	})
+
+	t.Run("notReciprocal", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := arrayInput{Arr: []UrlEntry{
+			{Loc: en, Alternates: alternates},
+			{Loc: fr, Alternates: []Alternate{
+				{Hreflang: "fr", Href: fr},
+				{Hreflang: "de", Href: de},
+			}},
+			{Loc: de, Alternates: []Alternate{
+				{Hreflang: "fr", Href: fr},
+			}},
+		}}
+		w := Writer{CheckAlternates: true}
+		var out bufferOuput
+
+		Ω(w.WriteAll(&out, &in)).Should(MatchError(
+			`entry "http://goiguide.com/en/" lists alternate ` +
+				`"http://goiguide.com/fr/" which does not list it back`))
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+
+	t.Run("missingEntry", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := arrayInput{Arr: []UrlEntry{
+			{Loc: en, Alternates: alternates},
+		}}
+		w := Writer{CheckAlternates: true}
+		var out bufferOuput
+
+		Ω(w.WriteAll(&out, &in)).Should(MatchError(
+			`entry "http://goiguide.com/en/" lists alternate ` +
+				`"http://goiguide.com/fr/" which does not list it back`))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := arrayInput{Arr: []UrlEntry{
+			{Loc: en, Alternates: alternates},
+		}}
+		var out bufferOuput
+
+		Ω(WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.index.Len()).ShouldNot(Equal(0))
+	})
+}
@@ -1,29 +1,85 @@
 package sitemap
 
-import "sync/atomic"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
 
+// ErrInputStopped is returned by ChannelInput.Feed once the writing stopped
+// reading the input because it failed.
+var ErrInputStopped = errors.New("sitemap: input is not read anymore")
+
+// ChannelInput is an Input fed by producer goroutines. The producers call
+// Feed for every entry and Close once done, or CloseWithError to abort the
+// writing with their own error.
 type ChannelInput struct {
 	channel      chan *UrlEntry
 	closed       int32
 	getUrlsetUrl func(int) string
+	// err is the error the input was closed with
+	err error
+
+	// stopped is closed once the consumer stops reading the input
+	stopped  chan struct{}
+	stopOnce sync.Once
+	stopErr  error
 }
 
 func NewChannelInput(getUrlsetUrl func(int) string) *ChannelInput {
 	return &ChannelInput{
 		channel:      make(chan *UrlEntry),
 		getUrlsetUrl: getUrlsetUrl,
+		stopped:      make(chan struct{}),
 	}
 }
 
-func (in *ChannelInput) Feed(entry *UrlEntry) {
+// Feed passes the entry to the consumer, waiting until it is read. If the
+// writing failed in the meantime, the entry is dropped and an error matching
+// ErrInputStopped, as well as the error the writing failed with, is
+// returned.
+func (in *ChannelInput) Feed(entry *UrlEntry) error {
+	return in.FeedContext(context.Background(), entry)
+}
+
+// FeedContext is like Feed but stops waiting for the consumer once the
+// context is done, in which case the entry is dropped and ctx.Err() is
+// returned.
+func (in *ChannelInput) FeedContext(ctx context.Context, entry *UrlEntry) error {
 	if atomic.LoadInt32(&in.closed) > 0 {
-		return
+		return nil
+	}
+
+	select {
+	case in.channel <- entry:
+		return nil
+	case <-in.stopped:
+		return in.stoppedErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stoppedErr returns the error returned by Feed once the input is stopped.
+func (in *ChannelInput) stoppedErr() error {
+	if in.stopErr == nil {
+		return ErrInputStopped
 	}
 
-	in.channel <- entry
+	return fmt.Errorf("%w: %w", ErrInputStopped, in.stopErr)
 }
 
+// Close marks the end of the input.
 func (in *ChannelInput) Close() {
+	in.CloseWithError(nil)
+}
+
+// CloseWithError marks the end of the input. A non-nil error aborts the
+// writing with that error, see ErrorReporter; the entries fed so far are
+// not listed in the index file.
+func (in *ChannelInput) CloseWithError(err error) {
 	if atomic.LoadInt32(&in.closed) > 0 {
 		return
 	}
@@ -33,6 +89,7 @@ func (in *ChannelInput) Close() {
 	}()
 
 	if atomic.SwapInt32(&in.closed, 1) == 0 {
+		in.err = err
 		close(in.channel)
 	}
 }
@@ -46,6 +103,22 @@ func (in *ChannelInput) Next() *UrlEntry {
 	return entry
 }
 
+// Err returns the error the input was closed with. It should be checked
+// once Next() returns nil.
+func (in *ChannelInput) Err() error {
+	return in.err
+}
+
+// Stop is called by the writer once it stops reading the input. A pending
+// and every following call to Feed on an input that is not closed returns
+// an error matching ErrInputStopped immediately.
+func (in *ChannelInput) Stop(err error) {
+	in.stopOnce.Do(func() {
+		in.stopErr = err
+		close(in.stopped)
+	})
+}
+
 func (in *ChannelInput) GetUrlsetUrl(n int) string {
 	if in.getUrlsetUrl == nil {
 		return ""
@@ -1,6 +1,8 @@
 package sitemap
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -34,6 +36,28 @@ func TestChannelInput_Close(t *testing.T) {
 		in.Close()
 		Ω(in.closed).Should(BeNumerically(">", 0))
 	})
+
+	t.Run("withError", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewChannelInput(nil)
+		in.CloseWithError(errors.New("producer error"))
+		Ω(in.channel).Should(BeClosed())
+		Ω(in.Next()).Should(BeNil())
+		Ω(in.Err()).Should(MatchError("producer error"))
+
+		in.CloseWithError(errors.New("other error"))
+		Ω(in.Err()).Should(MatchError("producer error"))
+	})
+
+	t.Run("noError", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewChannelInput(nil)
+		in.Close()
+		in.CloseWithError(errors.New("producer error"))
+		Ω(in.Err()).Should(BeNil())
+	})
 }
 
 func TestChannelInput_Feed(t *testing.T) {
@@ -62,7 +86,77 @@ func TestChannelInput_Feed(t *testing.T) {
 		in := NewChannelInput(nil)
 		in.Close()
 		Ω(in.channel).Should(BeClosed())
-		in.Feed(&UrlEntry{Loc: "one"})
+		Ω(in.Feed(&UrlEntry{Loc: "one"})).Should(BeNil())
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewChannelInput(nil)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- in.Feed(&UrlEntry{Loc: "one"})
+		}()
+		Consistently(errCh).ShouldNot(Receive())
+
+		writeErr := errors.New("write error")
+		in.Stop(writeErr)
+		var err error
+		Eventually(errCh).Should(Receive(&err))
+		Ω(err).Should(MatchError("sitemap: input is not read anymore: write error"))
+		Ω(errors.Is(err, ErrInputStopped)).Should(BeTrue())
+		Ω(errors.Is(err, writeErr)).Should(BeTrue())
+
+		in.Stop(nil)
+		Ω(in.Feed(&UrlEntry{Loc: "two"})).Should(MatchError(writeErr))
+		Ω(in.channel).ShouldNot(Receive())
+	})
+
+	t.Run("stoppedNoError", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewChannelInput(nil)
+		in.Stop(nil)
+		Ω(in.Feed(&UrlEntry{Loc: "one"})).Should(BeIdenticalTo(ErrInputStopped))
+	})
+}
+
+func TestChannelInput_FeedContext(t *testing.T) {
+	t.Run("entry", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewChannelInput(nil)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- in.FeedContext(context.Background(), &UrlEntry{Loc: "one"})
+		}()
+		Eventually(in.channel).Should(Receive(Equal(&UrlEntry{Loc: "one"})))
+		Eventually(errCh).Should(Receive(BeNil()))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewChannelInput(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- in.FeedContext(ctx, &UrlEntry{Loc: "one"})
+		}()
+		Consistently(errCh).ShouldNot(Receive())
+
+		cancel()
+		Eventually(errCh).Should(Receive(Equal(context.Canceled)))
+		Ω(in.channel).ShouldNot(Receive())
+	})
+
+	t.Run("closedChannel", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewChannelInput(nil)
+		in.Close()
+		Ω(in.FeedContext(context.Background(), &UrlEntry{Loc: "one"})).
+			Should(BeNil())
 	})
 }
 
@@ -168,8 +262,7 @@ func TestWriteAll_ChannelInput(t *testing.T) {
 		Ω(out.sitemaps).Should(HaveLen(1))
 		Ω(out.sitemaps[0].String()).Should(MatchXML(`
 			<?xml version="1.0" encoding="UTF-8"?>
-			<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
-				xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+			<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
 				<url> <loc>a</loc> </url>
 				<url> <loc>b</loc> </url>
 				<url> <loc>c</loc> </url>
@@ -215,8 +308,7 @@ func TestWriteAll_ChannelInput(t *testing.T) {
 		Ω(out.sitemaps).Should(HaveLen(1))
 		Ω(out.sitemaps[0].String()).Should(MatchXML(`
 			<?xml version="1.0" encoding="UTF-8"?>
-			<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
-				xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+			<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
 				<url> <loc>a</loc> </url>
 				<url> <loc>b</loc> </url>
 				<url> <loc>c</loc> </url>
@@ -243,4 +335,80 @@ func TestWriteAll_ChannelInput(t *testing.T) {
 		Ω(WriteAll(&out, in)).Should(BeNil())
 		assertOutput(&out, inputSize)
 	})
+	t.Run("producerError", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var out bufferOuput
+		in := NewChannelInput(nil)
+		go func(in *ChannelInput) {
+			in.Feed(&UrlEntry{Loc: "a"})
+			in.Feed(&UrlEntry{Loc: "b"})
+			in.CloseWithError(errors.New("producer error"))
+		}(in)
+
+		Ω(WriteAll(&out, in)).Should(MatchError("producer error"))
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(out.sitemaps[0].String()).ShouldNot(HaveSuffix(string(urlsetFooter)))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+
+	t.Run("writerError", func(t *testing.T) {
+		for _, workers := range []int{0, 2} {
+			workers := workers
+			t.Run(fmt.Sprintf("workers%d", workers), func(t *testing.T) {
+				RegisterTestingT(t)
+
+				in := NewChannelInput(nil)
+				errCh := make(chan error, 1)
+				go func(in *ChannelInput) {
+					for i := 0; ; i++ {
+						e := &UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%d", i)}
+						if i == 10 {
+							e.ChangeFreq = "sometimes"
+						}
+						if err := in.Feed(e); err != nil {
+							errCh <- err
+							return
+						}
+					}
+				}(in)
+
+				w := Writer{Workers: workers}
+				var out bufferOuput
+				err := w.WriteAll(&out, in)
+				Ω(err).Should(MatchError(
+					`entry "http://goiguide.com/10" has invalid changefreq "sometimes"`))
+
+				// The producer is not blocked forever.
+				var feedErr error
+				Eventually(errCh).Should(Receive(&feedErr))
+				Ω(errors.Is(feedErr, ErrInputStopped)).Should(BeTrue())
+				Ω(errors.Is(feedErr, err)).Should(BeTrue())
+			})
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		in := NewChannelInput(nil)
+		errCh := make(chan error, 1)
+		go func(in *ChannelInput) {
+			for i := 0; ; i++ {
+				if i == 5 {
+					cancel()
+				}
+				if err := in.Feed(&UrlEntry{Loc: "a"}); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}(in)
+
+		Ω(WriteAllContext(ctx, &bufferOuput{}, in)).Should(MatchError(context.Canceled))
+		var feedErr error
+		Eventually(errCh).Should(Receive(&feedErr))
+		Ω(feedErr).Should(MatchError(context.Canceled))
+	})
 }
@@ -0,0 +1,164 @@
+package sitemap
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// LocSet is a set of entry locations used by DedupInput to detect duplicate
+// entries. An implementation keeping the set on disk can be used for runs
+// too large to keep all the locations in memory.
+type LocSet interface {
+	// Add adds the location to the set, and reports whether it was in the
+	// set already.
+	Add(loc string) bool
+}
+
+// DedupInput is an Input wrapper dropping the entries whose location was
+// already returned during the run.
+type DedupInput struct {
+	underlying Input
+	set        LocSet
+	duplicates int
+}
+
+// NewDedupInput returns a DedupInput reading entries from the given input
+// and detecting duplicates with the given set. A nil set means an exact
+// in-memory set, which keeps all the locations in memory; NewBloomFilter
+// provides a memory-bounded alternative.
+func NewDedupInput(in Input, set LocSet) *DedupInput {
+	if set == nil {
+		set = exactLocSet{}
+	}
+
+	return &DedupInput{
+		underlying: in,
+		set:        set,
+	}
+}
+
+// Next returns the next entry of the underlying input whose location was
+// not returned before.
+func (in *DedupInput) Next() *UrlEntry {
+	for {
+		entry := in.underlying.Next()
+		if entry == nil || !in.set.Add(entry.Loc) {
+			return entry
+		}
+
+		in.duplicates++
+	}
+}
+
+func (in *DedupInput) GetUrlsetUrl(idx int) string {
+	return in.underlying.GetUrlsetUrl(idx)
+}
+
+// GetUrlsetLastMod returns the lastmod value provided by the underlying
+// input, if it implements UrlsetLastModProvider.
+func (in *DedupInput) GetUrlsetLastMod(idx int) time.Time {
+	if p, ok := in.underlying.(UrlsetLastModProvider); ok {
+		return p.GetUrlsetLastMod(idx)
+	}
+
+	return time.Time{}
+}
+
+// Err returns the error reported by the underlying input, if it implements
+// ErrorReporter.
+func (in *DedupInput) Err() error {
+	return inputErr(in.underlying)
+}
+
+// Stop notifies the underlying input, if it implements Stopper.
+func (in *DedupInput) Stop(err error) {
+	stopInput(in.underlying, err)
+}
+
+// Duplicates returns the number of entries dropped so far.
+func (in *DedupInput) Duplicates() int {
+	return in.duplicates
+}
+
+// exactLocSet is a LocSet keeping all the locations in memory.
+type exactLocSet map[string]struct{}
+
+func (s exactLocSet) Add(loc string) bool {
+	if _, ok := s[loc]; ok {
+		return true
+	}
+
+	s[loc] = struct{}{}
+	return false
+}
+
+// BloomFilter is a LocSet using a fixed amount of memory regardless of the
+// number of locations. In exchange, it reports a new location as present
+// with a small probability, so DedupInput might drop a unique entry.
+type BloomFilter struct {
+	bits  []uint64
+	nbits uint64
+	nhash int
+	seed  maphash.Seed
+}
+
+// The bounds of the probability of false positives of a BloomFilter.
+const (
+	minFalsePositiveRate = 1e-9
+	maxFalsePositiveRate = 0.5
+)
+
+// NewBloomFilter returns a BloomFilter sized for the given number of
+// locations with the given probability of false positives, e.g. 0.001.
+// For 10 million locations and the probability of 0.001, the filter takes
+// about 18MB. A probability outside of the (0, 1) range is clamped to
+// [1e-9, 0.5].
+func NewBloomFilter(n int, falsePositiveRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if !(falsePositiveRate >= minFalsePositiveRate) {
+		falsePositiveRate = minFalsePositiveRate
+	}
+	if falsePositiveRate > maxFalsePositiveRate {
+		falsePositiveRate = maxFalsePositiveRate
+	}
+
+	// The optimal number of bits and hash functions.
+	nbits := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	nhash := int(math.Round(nbits / float64(n) * math.Ln2))
+	if nhash < 1 {
+		nhash = 1
+	}
+
+	words := (uint64(nbits) + 63) / 64
+	if words < 1 {
+		words = 1
+	}
+	return &BloomFilter{
+		bits:  make([]uint64, words),
+		nbits: words * 64,
+		nhash: nhash,
+		seed:  maphash.MakeSeed(),
+	}
+}
+
+func (f *BloomFilter) Add(loc string) bool {
+	// The bit positions are derived from a single hash using double hashing.
+	h1 := maphash.String(f.seed, loc)
+	h2 := bits.RotateLeft64(h1, 32) | 1
+
+	present := true
+	for i := 0; i < f.nhash; i++ {
+		pos := (h1 + uint64(i)*h2) % f.nbits
+		word, mask := pos/64, uint64(1)<<(pos%64)
+		if f.bits[word]&mask == 0 {
+			present = false
+			f.bits[word] |= mask
+		}
+	}
+
+	return present
+}
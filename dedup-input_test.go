@@ -0,0 +1,180 @@
+package sitemap
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDedupInput(t *testing.T) {
+	entries := []UrlEntry{
+		{Loc: "http://goiguide.com/1"},
+		{Loc: "http://goiguide.com/2"},
+		{Loc: "http://goiguide.com/1"},
+		{Loc: "http://goiguide.com/3"},
+		{Loc: "http://goiguide.com/2"},
+		{Loc: "http://goiguide.com/2"},
+	}
+
+	t.Run("exact", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewDedupInput(&arrayInput{Arr: entries}, nil)
+		var locs []string
+		for e := in.Next(); e != nil; e = in.Next() {
+			locs = append(locs, e.Loc)
+		}
+		Ω(locs).Should(Equal([]string{
+			"http://goiguide.com/1",
+			"http://goiguide.com/2",
+			"http://goiguide.com/3",
+		}))
+		Ω(in.Duplicates()).Should(Equal(3))
+		Ω(in.Next()).Should(BeNil())
+	})
+
+	t.Run("writeAll", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		// Duplicates spread over several urlset files.
+		in := NewDedupInput(&dynamicInput{
+			Size: 50_000 * 3,
+			CustomEntry: func(idx int) *UrlEntry {
+				return &UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%d", idx%(50_000+5))}
+			},
+			CustomUrlsetUrl: func(idx int) string {
+				return fmt.Sprintf("urlset %03d", idx)
+			},
+		}, nil)
+		var out bufferOuput
+		Ω(WriteAll(&out, in)).Should(BeNil())
+		Ω(in.Duplicates()).Should(Equal(50_000*3 - (50_000 + 5)))
+		assertOutput(&out, 50_000+5)
+	})
+
+	t.Run("bloomFilter", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := NewDedupInput(&arrayInput{Arr: entries}, NewBloomFilter(100, 0.001))
+		var n int
+		for e := in.Next(); e != nil; e = in.Next() {
+			n++
+		}
+		Ω(n).Should(Equal(3))
+		Ω(in.Duplicates()).Should(Equal(3))
+	})
+
+	t.Run("forwarding", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		lastMod := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+		in := NewDedupInput(&lastModInput{
+			arrayInput: arrayInput{Arr: entries},
+			LastMods:   map[int]time.Time{1: lastMod},
+		}, nil)
+		Ω(in.GetUrlsetUrl(1)).Should(Equal("urlset no. 2"))
+		Ω(in.GetUrlsetLastMod(1)).Should(Equal(lastMod))
+		Ω(in.GetUrlsetLastMod(0).IsZero()).Should(BeTrue())
+
+		in = NewDedupInput(&arrayInput{Arr: entries}, nil)
+		Ω(in.GetUrlsetLastMod(1).IsZero()).Should(BeTrue())
+	})
+}
+
+func TestBloomFilter(t *testing.T) {
+	t.Run("sizing", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		f := NewBloomFilter(10_000_000, 0.001)
+		Ω(len(f.bits) * 8).Should(BeNumerically("~", 18_000_000, 100_000))
+		Ω(f.nhash).Should(Equal(10))
+
+		f = NewBloomFilter(0, 0.5)
+		Ω(f.bits).Should(HaveLen(1))
+		Ω(f.nhash).Should(Equal(1))
+	})
+
+	t.Run("invalidRate", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		for _, rate := range []float64{1, 2, math.Inf(1)} {
+			f := NewBloomFilter(100, rate)
+			Ω(f.nbits).Should(Equal(uint64(192)))
+			Ω(f.nhash).Should(Equal(1))
+			Ω(f.Add("http://goiguide.com/")).Should(BeFalse())
+			Ω(f.Add("http://goiguide.com/")).Should(BeTrue())
+		}
+
+		for _, rate := range []float64{0, -1, math.NaN()} {
+			f := NewBloomFilter(100, rate)
+			Ω(f.nbits).Should(Equal(NewBloomFilter(100, 1e-9).nbits))
+			Ω(f.Add("http://goiguide.com/")).Should(BeFalse())
+			Ω(f.Add("http://goiguide.com/")).Should(BeTrue())
+		}
+	})
+
+	t.Run("falsePositives", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		n := 100_000
+		f := NewBloomFilter(n, 0.01)
+		var falsePositives int
+		for i := 0; i < n; i++ {
+			if f.Add(fmt.Sprintf("http://goiguide.com/%d", i)) {
+				falsePositives++
+			}
+		}
+		Ω(float64(falsePositives) / float64(n)).Should(BeNumerically("<", 0.01))
+
+		// There are no false negatives.
+		for i := 0; i < n; i++ {
+			Ω(f.Add(fmt.Sprintf("http://goiguide.com/%d", i))).Should(BeTrue())
+		}
+
+		// Every checked location is added, so check few of them to keep the
+		// filter at its capacity.
+		falsePositives = 0
+		nchecks := n / 100
+		for i := 0; i < nchecks; i++ {
+			if f.Add(fmt.Sprintf("http://goiguide.com/other/%d", i)) {
+				falsePositives++
+			}
+		}
+		Ω(float64(falsePositives) / float64(nchecks)).Should(BeNumerically("<", 0.03))
+	})
+}
+
+func BenchmarkDedupInput(b *testing.B) {
+	locs := make([]string, 1_000)
+	for i := range locs {
+		locs[i] = "http://www.example.com/" + strings.Repeat("a", i%50) + fmt.Sprint(i)
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Set  func() LocSet
+	}{
+		{Name: "exact", Set: func() LocSet { return nil }},
+		{Name: "bloom", Set: func() LocSet { return NewBloomFilter(len(locs), 0.001) }},
+	} {
+		tc := tc
+		b.Run(tc.Name, func(b *testing.B) {
+			in := dynamicInput{
+				Size: len(locs),
+				CustomEntry: func(idx int) *UrlEntry {
+					return &UrlEntry{Loc: locs[idx]}
+				},
+			}
+			for n := 0; n < b.N; n++ {
+				in.Reset()
+				dedup := NewDedupInput(&in, tc.Set())
+				for dedup.Next() != nil {
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DirOutput is an Output writing sitemap files into a directory.
+//
+// The files are written into temporary files in the same directory first.
+// Once WriteAll succeeds, Commit() renames them into place, so a failed or
+// crashed run never leaves a half-written set of files behind. Urlset files
+// are renamed before the index, hence the published index never refers to
+// a missing file.
+//
+// The first index file is named IndexName. When the urlset files do not fit
+// into a single index file, the following ones are named after IndexPattern.
+//
+// DirOutput implements SectionOutput: the urlset files of the sections
+// written by Writer.WriteSections are named after SectionPattern. A section
+// name containing path separators or ".." is rejected.
+type DirOutput struct {
+	// Dir is the directory the files are written to.
+	Dir string
+	// UrlsetPattern is the name of urlset files, formatted with the index of
+	// the file, e.g. "sitemap-%d.xml".
+	UrlsetPattern string
+	// SectionPattern is the name of the urlset files of a section, formatted
+	// with the name of the section and the index of the file within the
+	// section, e.g. "sitemap-%s-%d.xml".
+	SectionPattern string
+	// IndexName is the name of the index file, e.g. "sitemap.xml".
+	IndexName string
+	// IndexPattern is the name of the index files following the first one,
+	// formatted with the index of the file starting from 1, e.g.
+	// "sitemap-index-%d.xml".
+	IndexPattern string
+	// BaseUrl is the public URL of the directory, e.g.
+	// "https://example.com/sitemaps/". When set, the output provides the
+	// URLs of urlset files listed in the index, see UrlsetUrlProvider, and
+	// of the index files, see IndexUrlProvider.
+	BaseUrl string
+
+	// urlsets holds the written urlset files, nil for the ones kept from
+	// the previous run
+	urlsets  []*dirFile
+	indexes  []*dirFile
+	sections []*dirSection
+}
+
+// NewDirOutput returns a DirOutput writing to the given directory, naming
+// urlset files "sitemap-N.xml", or "sitemap-SECTION-N.xml" for sections, the
+// index file "sitemap.xml", and the following index files, if any,
+// "sitemap-index-N.xml".
+func NewDirOutput(dir string) *DirOutput {
+	return &DirOutput{
+		Dir:            dir,
+		UrlsetPattern:  "sitemap-%d.xml",
+		SectionPattern: "sitemap-%s-%d.xml",
+		IndexName:      "sitemap.xml",
+		IndexPattern:   "sitemap-index-%d.xml",
+	}
+}
+
+func (o *DirOutput) Index() io.Writer {
+	if len(o.indexes) > 0 && o.IndexPattern == "" {
+		return errWriter{err: errors.New(
+			"sitemap: IndexPattern is required for more than one index file")}
+	}
+
+	f, err := o.createTemp()
+	if err != nil {
+		return errWriter{err: err}
+	}
+
+	o.indexes = append(o.indexes, f)
+	return f
+}
+
+func (o *DirOutput) Urlset() io.Writer {
+	f, err := o.createTemp()
+	if err != nil {
+		return errWriter{err: err}
+	}
+
+	o.urlsets = append(o.urlsets, f)
+	return f
+}
+
+// SkipUrlset keeps the published urlset file at the next index, see
+// UrlsetSkipper. It returns false if the file does not exist.
+func (o *DirOutput) SkipUrlset() bool {
+	if _, err := os.Stat(o.urlsetPath(len(o.urlsets))); err != nil {
+		return false
+	}
+
+	o.urlsets = append(o.urlsets, nil)
+	return true
+}
+
+// GetUrlsetUrl returns the public URL of the urlset file at the given index,
+// or an empty string if BaseUrl is not set.
+func (o *DirOutput) GetUrlsetUrl(idx int) string {
+	if o.BaseUrl == "" {
+		return ""
+	}
+
+	return o.BaseUrl + fmt.Sprintf(o.UrlsetPattern, idx)
+}
+
+// Section returns the output of the urlset files of the named section, see
+// SectionOutput. The files are published by Commit() along with the rest.
+func (o *DirOutput) Section(name string) Output {
+	for _, sec := range o.sections {
+		if sec.name == name {
+			return sec
+		}
+	}
+
+	sec := &dirSection{dir: o, name: name}
+	o.sections = append(o.sections, sec)
+	return sec
+}
+
+// GetIndexUrl returns the public URL of the index file at the given index,
+// or an empty string if BaseUrl is not set.
+func (o *DirOutput) GetIndexUrl(idx int) string {
+	if o.BaseUrl == "" {
+		return ""
+	}
+
+	return o.BaseUrl + o.indexName(idx)
+}
+
+// Commit publishes the written files by renaming them into place, and
+// removes urlset and index files left from previous runs that produced more
+// files. It fails if the index files are not complete, i.e. WriteAll did not
+// succeed.
+//
+// The urlset files of a section written by a previous run are removed only
+// if the section is written again; the files of a section that is not
+// written anymore are left in place.
+func (o *DirOutput) Commit() error {
+	if len(o.indexes) == 0 {
+		return errors.New("sitemap: cannot commit incomplete files")
+	}
+	for _, f := range o.indexes {
+		if !f.finalized {
+			return errors.New("sitemap: cannot commit incomplete files")
+		}
+	}
+
+	if err := renameFiles(o.urlsets, o.urlsetPath); err != nil {
+		return err
+	}
+	for _, sec := range o.sections {
+		if err := renameFiles(sec.urlsets, sec.urlsetPath); err != nil {
+			return err
+		}
+	}
+	// The first index file is renamed last, the following ones are not
+	// known to anyone before that.
+	for i := len(o.indexes) - 1; i >= 0; i-- {
+		if err := os.Rename(o.indexes[i].Name(), o.indexPath(i)); err != nil {
+			return err
+		}
+	}
+
+	if err := removeFrom(len(o.urlsets), o.urlsetPath); err != nil {
+		return err
+	}
+	for _, sec := range o.sections {
+		if err := removeFrom(len(sec.urlsets), sec.urlsetPath); err != nil {
+			return err
+		}
+	}
+	if o.IndexPattern != "" {
+		if err := removeFrom(len(o.indexes), o.indexPath); err != nil {
+			return err
+		}
+	}
+
+	o.urlsets = nil
+	o.indexes = nil
+	o.sections = nil
+	return nil
+}
+
+// renameFiles renames the given temporary files to their paths, skipping
+// the nil ones.
+func renameFiles(files []*dirFile, path func(int) string) error {
+	for i, f := range files {
+		if f == nil {
+			continue
+		}
+		if err := os.Rename(f.Name(), path(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeFrom removes the files at the given index and the following ones,
+// until a file does not exist.
+func removeFrom(idx int, path func(int) string) error {
+	for i := idx; ; i++ {
+		err := os.Remove(path(i))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Abort removes the temporary files written so far, leaving the previously
+// published files intact.
+func (o *DirOutput) Abort() error {
+	files := append(o.urlsets, o.indexes...)
+	for _, sec := range o.sections {
+		files = append(files, sec.urlsets...)
+	}
+
+	var errs []error
+	for _, f := range files {
+		if f == nil {
+			continue
+		}
+
+		if !f.finalized {
+			_ = f.Close()
+		}
+		if err := os.Remove(f.Name()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	o.urlsets = nil
+	o.indexes = nil
+	o.sections = nil
+	return errors.Join(errs...)
+}
+
+func (o *DirOutput) createTemp() (*dirFile, error) {
+	f, err := os.CreateTemp(o.Dir, ".sitemap-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// Temporary files are private by default, while the published files
+	// are usually served by a web server.
+	if err := f.Chmod(0o644); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &dirFile{File: f}, nil
+}
+
+func (o *DirOutput) urlsetPath(idx int) string {
+	return filepath.Join(o.Dir, fmt.Sprintf(o.UrlsetPattern, idx))
+}
+
+func (o *DirOutput) indexName(idx int) string {
+	if idx == 0 {
+		return o.IndexName
+	}
+
+	return fmt.Sprintf(o.IndexPattern, idx)
+}
+
+func (o *DirOutput) indexPath(idx int) string {
+	return filepath.Join(o.Dir, o.indexName(idx))
+}
+
+// dirSection is the output of the urlset files of a section of a DirOutput.
+type dirSection struct {
+	dir     *DirOutput
+	name    string
+	urlsets []*dirFile
+}
+
+func (o *dirSection) Index() io.Writer {
+	return errWriter{err: errors.New("sitemap: sections have no index file")}
+}
+
+func (o *dirSection) Urlset() io.Writer {
+	if o.dir.SectionPattern == "" {
+		return errWriter{err: errors.New(
+			"sitemap: SectionPattern is required for sections")}
+	}
+	if err := checkSectionName(o.name); err != nil {
+		return errWriter{err: err}
+	}
+
+	f, err := o.dir.createTemp()
+	if err != nil {
+		return errWriter{err: err}
+	}
+
+	o.urlsets = append(o.urlsets, f)
+	return f
+}
+
+// GetUrlsetUrl returns the public URL of the urlset file of the section at
+// the given index, or an empty string if BaseUrl is not set.
+func (o *dirSection) GetUrlsetUrl(idx int) string {
+	if o.dir.BaseUrl == "" {
+		return ""
+	}
+
+	return o.dir.BaseUrl + o.urlsetName(idx)
+}
+
+func (o *dirSection) urlsetName(idx int) string {
+	return fmt.Sprintf(o.dir.SectionPattern, o.name, idx)
+}
+
+func (o *dirSection) urlsetPath(idx int) string {
+	return filepath.Join(o.dir.Dir, o.urlsetName(idx))
+}
+
+// dirFile is a temporary file holding a single sitemap file.
+type dirFile struct {
+	*os.File
+	finalized bool
+}
+
+func (f *dirFile) Finalize() error {
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	f.finalized = true
+	return nil
+}
@@ -0,0 +1,392 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDirOutput(t *testing.T) {
+	customEntry := func(idx int) *UrlEntry {
+		return &UrlEntry{
+			Loc: fmt.Sprintf("http://goiguide.com/%d", idx),
+		}
+	}
+	customUrl := func(idx int) string {
+		return fmt.Sprintf("urlset %03d", idx)
+	}
+
+	t.Run("simple", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		dir := t.TempDir()
+		in := dynamicInput{
+			Size:            2,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		out := NewDirOutput(dir)
+
+		Ω(WriteAll(out, &in)).Should(BeNil())
+		Ω(listDir(dir)).Should(HaveLen(2))
+		for _, name := range listDir(dir) {
+			Ω(name).Should(HavePrefix(".sitemap-"))
+		}
+
+		Ω(out.Commit()).Should(BeNil())
+		Ω(listDir(dir)).Should(Equal([]string{"sitemap-0.xml", "sitemap.xml"}))
+		Ω(readFile(dir, "sitemap.xml")).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>urlset 000</loc>
+  </sitemap>
+</sitemapindex>
+		`)))
+		Ω(readFile(dir, "sitemap-0.xml")).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>http://goiguide.com/0</loc>
+  </url>
+  <url>
+    <loc>http://goiguide.com/1</loc>
+  </url>
+</urlset>
+		`)))
+
+		st, err := os.Stat(filepath.Join(dir, "sitemap.xml"))
+		Ω(err).Should(BeNil())
+		Ω(st.Mode().Perm()).Should(Equal(os.FileMode(0o644)))
+	})
+
+	t.Run("customNames", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		dir := t.TempDir()
+		in := dynamicInput{
+			Size:            50_000*2 + 1,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		out := NewDirOutput(dir)
+		out.UrlsetPattern = "listings-%03d.xml"
+		out.IndexName = "index.xml"
+
+		Ω(WriteAll(out, &in)).Should(BeNil())
+		Ω(out.Commit()).Should(BeNil())
+		Ω(listDir(dir)).Should(Equal([]string{
+			"index.xml",
+			"listings-000.xml",
+			"listings-001.xml",
+			"listings-002.xml",
+		}))
+
+		var buffers bufferOuput
+		buffers.index.WriteString(readFile(dir, "index.xml"))
+		for i := 0; i < 3; i++ {
+			buffers.sitemaps = append(buffers.sitemaps, bytes.Buffer{})
+			buffers.sitemaps[i].WriteString(
+				readFile(dir, fmt.Sprintf("listings-%03d.xml", i)))
+		}
+		assertOutput(&buffers, in.Size)
+	})
+
+	t.Run("baseUrl", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		dir := t.TempDir()
+		in := NewChannelInput(nil)
+		out := NewDirOutput(dir)
+		out.UrlsetPattern = "sitemap-%d.xml.gz"
+		out.IndexName = "sitemap.xml.gz"
+		out.BaseUrl = "https://goiguide.com/sitemaps/"
+		gzOut, err := NewGzipOutput(out, gzip.DefaultCompression)
+		Ω(err).Should(BeNil())
+
+		go func() {
+			for i := 0; i < 50_000+1; i++ {
+				in.Feed(customEntry(i))
+			}
+			in.Close()
+		}()
+
+		Ω(WriteAll(gzOut, in)).Should(BeNil())
+		Ω(out.Commit()).Should(BeNil())
+		Ω(listDir(dir)).Should(Equal([]string{
+			"sitemap-0.xml.gz", "sitemap-1.xml.gz", "sitemap.xml.gz",
+		}))
+
+		f, err := os.Open(filepath.Join(dir, "sitemap.xml.gz"))
+		Ω(err).Should(BeNil())
+		defer f.Close()
+		Ω(gunzip(f)).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>https://goiguide.com/sitemaps/sitemap-0.xml.gz</loc>
+  </sitemap>
+  <sitemap>
+    <loc>https://goiguide.com/sitemaps/sitemap-1.xml.gz</loc>
+  </sitemap>
+</sitemapindex>
+		`)))
+	})
+
+	t.Run("inputUrlFirst", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		dir := t.TempDir()
+		in := dynamicInput{
+			Size:            1,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		out := NewDirOutput(dir)
+		out.BaseUrl = "https://goiguide.com/"
+
+		Ω(out.GetUrlsetUrl(7)).Should(Equal("https://goiguide.com/sitemap-7.xml"))
+		Ω(WriteAll(out, &in)).Should(BeNil())
+		Ω(out.Commit()).Should(BeNil())
+		Ω(readFile(dir, "sitemap.xml")).Should(ContainSubstring(
+			"<loc>urlset 000</loc>"))
+	})
+
+	t.Run("staleFiles", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		dir := t.TempDir()
+		for _, name := range []string{
+			"sitemap.xml", "sitemap-0.xml", "sitemap-1.xml", "sitemap-2.xml",
+			"other.xml",
+		} {
+			Ω(os.WriteFile(filepath.Join(dir, name), []byte("old"), 0o644)).
+				Should(BeNil())
+		}
+
+		in := dynamicInput{
+			Size:            3,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		out := NewDirOutput(dir)
+
+		Ω(WriteAll(out, &in)).Should(BeNil())
+		Ω(out.Commit()).Should(BeNil())
+		Ω(listDir(dir)).Should(Equal([]string{
+			"other.xml", "sitemap-0.xml", "sitemap.xml",
+		}))
+		Ω(readFile(dir, "sitemap-0.xml")).Should(ContainSubstring("/2</loc>"))
+		Ω(readFile(dir, "other.xml")).Should(Equal("old"))
+	})
+
+	t.Run("multipleIndexes", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		dir := t.TempDir()
+		Ω(os.WriteFile(filepath.Join(dir, "sitemap-index-3.xml"), []byte("old"), 0o644)).
+			Should(BeNil())
+		write := func(w io.Writer, content string) {
+			_, err := io.WriteString(w, content)
+			Ω(err).Should(BeNil())
+			Ω(finalize(w)).Should(BeNil())
+		}
+
+		out := NewDirOutput(dir)
+		out.BaseUrl = "https://goiguide.com/"
+		write(out.Urlset(), "urlset 0")
+		write(out.Index(), "index 0")
+		write(out.Index(), "index 1")
+		write(out.Index(), "index 2")
+		Ω(out.Commit()).Should(BeNil())
+		Ω(listDir(dir)).Should(Equal([]string{
+			"sitemap-0.xml", "sitemap-index-1.xml", "sitemap-index-2.xml", "sitemap.xml",
+		}))
+		Ω(readFile(dir, "sitemap.xml")).Should(Equal("index 0"))
+		Ω(readFile(dir, "sitemap-index-2.xml")).Should(Equal("index 2"))
+		Ω(out.GetIndexUrl(0)).Should(Equal("https://goiguide.com/sitemap.xml"))
+		Ω(out.GetIndexUrl(2)).Should(Equal("https://goiguide.com/sitemap-index-2.xml"))
+
+		// The following run needs a single index file.
+		write(out.Urlset(), "urlset 0")
+		write(out.Index(), "index 0")
+		Ω(out.Commit()).Should(BeNil())
+		Ω(listDir(dir)).Should(Equal([]string{"sitemap-0.xml", "sitemap.xml"}))
+	})
+
+	t.Run("sections", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		dir := t.TempDir()
+		write := func(blogSize int) {
+			newSection := func(name string, size int) Section {
+				return Section{Name: name, Input: &dynamicInput{
+					Size: size,
+					CustomEntry: func(idx int) *UrlEntry {
+						return &UrlEntry{
+							Loc: fmt.Sprintf("http://goiguide.com/%s/%d", name, idx),
+						}
+					},
+				}}
+			}
+
+			out := NewDirOutput(dir)
+			out.BaseUrl = "https://goiguide.com/"
+			_, err := new(Writer).WriteSections(context.Background(), out, []Section{
+				newSection("listings", 3),
+				newSection("blog", blogSize),
+			})
+			Ω(err).Should(BeNil())
+			Ω(out.Commit()).Should(BeNil())
+		}
+
+		write(50_000 + 1)
+		Ω(listDir(dir)).Should(Equal([]string{
+			"sitemap-blog-0.xml", "sitemap-blog-1.xml", "sitemap-listings-0.xml",
+			"sitemap.xml",
+		}))
+		Ω(readFile(dir, "sitemap.xml")).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>https://goiguide.com/sitemap-listings-0.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>https://goiguide.com/sitemap-blog-0.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>https://goiguide.com/sitemap-blog-1.xml</loc>
+  </sitemap>
+</sitemapindex>
+		`)))
+		Ω(readFile(dir, "sitemap-listings-0.xml")).
+			Should(ContainSubstring("<loc>http://goiguide.com/listings/2</loc>"))
+
+		// The stale urlset files of the section are removed.
+		write(2)
+		Ω(listDir(dir)).Should(Equal([]string{
+			"sitemap-blog-0.xml", "sitemap-listings-0.xml", "sitemap.xml",
+		}))
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Run("incomplete", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			dir := t.TempDir()
+			Ω(os.WriteFile(filepath.Join(dir, "sitemap.xml"), []byte("old"), 0o644)).
+				Should(BeNil())
+
+			out := NewDirOutput(dir)
+			Ω(out.Commit()).Should(MatchError("sitemap: cannot commit incomplete files"))
+
+			_, _ = out.Urlset().Write([]byte("partial"))
+			Ω(out.Commit()).Should(MatchError("sitemap: cannot commit incomplete files"))
+
+			Ω(out.Abort()).Should(BeNil())
+			Ω(listDir(dir)).Should(Equal([]string{"sitemap.xml"}))
+			Ω(readFile(dir, "sitemap.xml")).Should(Equal("old"))
+		})
+
+		t.Run("abortAfterWrite", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			dir := t.TempDir()
+			in := dynamicInput{
+				Size:            50_000 + 1,
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			out := NewDirOutput(dir)
+
+			Ω(WriteAll(out, &in)).Should(BeNil())
+			Ω(listDir(dir)).Should(HaveLen(3))
+			Ω(out.Abort()).Should(BeNil())
+			Ω(listDir(dir)).Should(BeEmpty())
+		})
+
+		t.Run("noIndexPattern", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			out := NewDirOutput(t.TempDir())
+			out.IndexPattern = ""
+			_, err := out.Index().Write([]byte("index 0"))
+			Ω(err).Should(BeNil())
+			_, err = out.Index().Write([]byte("index 1"))
+			Ω(err).Should(MatchError(
+				"sitemap: IndexPattern is required for more than one index file"))
+			Ω(out.Abort()).Should(BeNil())
+		})
+
+		t.Run("noSectionPattern", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			out := NewDirOutput(t.TempDir())
+			out.SectionPattern = ""
+			_, err := out.Section("blog").Urlset().Write([]byte("urlset 0"))
+			Ω(err).Should(MatchError("sitemap: SectionPattern is required for sections"))
+		})
+
+		t.Run("invalidSectionName", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			dir := filepath.Join(t.TempDir(), "sitemaps")
+			Ω(os.Mkdir(dir, 0o755)).Should(BeNil())
+			out := NewDirOutput(dir)
+			_, err := out.Section("../x").Urlset().Write([]byte("urlset 0"))
+			Ω(err).Should(MatchError(`sitemap: invalid section name "../x"`))
+			Ω(listDir(dir)).Should(BeEmpty())
+			Ω(listDir(filepath.Dir(dir))).Should(Equal([]string{"sitemaps"}))
+		})
+
+		t.Run("abortSections", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			dir := t.TempDir()
+			out := NewDirOutput(dir)
+			_, _ = out.Section("blog").Urlset().Write([]byte("urlset 0"))
+			Ω(listDir(dir)).Should(HaveLen(1))
+			Ω(out.Abort()).Should(BeNil())
+			Ω(listDir(dir)).Should(BeEmpty())
+		})
+
+		t.Run("missingDir", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := dynamicInput{
+				Size:            3,
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			out := NewDirOutput(filepath.Join(t.TempDir(), "missing"))
+
+			Ω(WriteAll(out, &in)).Should(MatchError(os.ErrNotExist))
+			Ω(out.Commit()).ShouldNot(BeNil())
+		})
+	})
+}
+
+func listDir(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	Ω(err).Should(BeNil())
+
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func readFile(dir, name string) string {
+	bs, err := os.ReadFile(filepath.Join(dir, name))
+	Ω(err).Should(BeNil())
+	return string(bs)
+}
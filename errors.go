@@ -0,0 +1,105 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidEntry is matched, using errors.Is, by the errors returned for
+	// entries violating the protocol, e.g. an entry with an invalid
+	// changefreq, a missing required field, or an invalid URL (see
+	// EntryError). Errors matching ErrLimitExceeded match it too.
+	ErrInvalidEntry = errors.New("sitemap: invalid entry")
+	// ErrLimitExceeded is matched, using errors.Is, by the errors returned
+	// for entries exceeding a limit of the protocol, e.g. an entry with more
+	// than 1,000 images or an entry too large for a urlset file.
+	ErrLimitExceeded = errors.New("sitemap: limit exceeded")
+)
+
+// FileKind is the kind of a sitemap file.
+type FileKind int
+
+const (
+	UrlsetFile FileKind = iota + 1
+	IndexFile
+)
+
+func (k FileKind) String() string {
+	switch k {
+	case UrlsetFile:
+		return "urlset"
+	case IndexFile:
+		return "index"
+	default:
+		return fmt.Sprintf("FileKind(%d)", int(k))
+	}
+}
+
+// WriteError is returned when writing or finalizing a file provided by the
+// output fails.
+type WriteError struct {
+	Kind FileKind
+	// File is the index of the file, as passed to GetUrlsetUrl() or
+	// GetIndexUrl().
+	File int
+	// Section is the name of the section of the urlset file, see
+	// Writer.WriteSections.
+	Section string
+	// Entries is the number of entries, or sitemaps for the index file,
+	// written to the file before the failure. The files rendered in memory
+	// before being written, e.g. when writing in parallel, are written at
+	// once, so the number is zero unless the finalization failed.
+	Entries int
+	Err     error
+}
+
+func (e *WriteError) Error() string {
+	if e.Kind == IndexFile && e.File == 0 {
+		return fmt.Sprintf("sitemap: writing index file failed after %d "+
+			"entries: %v", e.Entries, e.Err)
+	}
+
+	if e.Section != "" {
+		return fmt.Sprintf("sitemap: writing %s file %d of section %q failed "+
+			"after %d entries: %v", e.Kind, e.File, e.Section, e.Entries, e.Err)
+	}
+
+	return fmt.Sprintf("sitemap: writing %s file %d failed after %d entries: %v",
+		e.Kind, e.File, e.Entries, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+func (e *EntryError) Is(target error) bool {
+	return target == ErrInvalidEntry
+}
+
+// validationError is an error of an entry violating the protocol, matching
+// ErrInvalidEntry, and ErrLimitExceeded if limit is set.
+type validationError struct {
+	err   error
+	limit bool
+}
+
+func invalidEntryf(format string, args ...any) error {
+	return &validationError{err: fmt.Errorf(format, args...)}
+}
+
+func limitExceededf(format string, args ...any) error {
+	return &validationError{err: fmt.Errorf(format, args...), limit: true}
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *validationError) Unwrap() error {
+	return e.err
+}
+
+func (e *validationError) Is(target error) bool {
+	return target == ErrInvalidEntry || (e.limit && target == ErrLimitExceeded)
+}
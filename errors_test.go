@@ -0,0 +1,187 @@
+package sitemap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWriteError(t *testing.T) {
+	t.Run("urlsetEntries", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size: 50_000 + 10,
+			DefaultEntry: UrlEntry{
+				Loc: "http://goiguide.com/",
+			},
+		}
+		// The header of the second file and 4 entries are written.
+		out := failAfterOutput{FailUrlset: 1, Writes: 2 + 4}
+		err := WriteAll(&out, &in)
+		Ω(err).Should(MatchError(
+			"sitemap: writing urlset file 1 failed after 4 entries: shortWriter error"))
+
+		var werr *WriteError
+		Ω(errors.As(err, &werr)).Should(BeTrue())
+		Ω(werr.Kind).Should(Equal(UrlsetFile))
+		Ω(werr.File).Should(Equal(1))
+		Ω(werr.Entries).Should(Equal(4))
+		Ω(werr.Err).Should(MatchError("shortWriter error"))
+		Ω(errors.Is(err, ErrInvalidEntry)).Should(BeFalse())
+	})
+
+	t.Run("indexEntries", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size: 50_000*3 + 1,
+			DefaultEntry: UrlEntry{
+				Loc: "http://goiguide.com/",
+			},
+		}
+		// The header and 3 sitemaps are written.
+		out := failAfterOutput{FailUrlset: -1, Writes: 1 + 3*5}
+		err := WriteAll(&out, &in)
+
+		var werr *WriteError
+		Ω(errors.As(err, &werr)).Should(BeTrue())
+		Ω(werr.Kind).Should(Equal(IndexFile))
+		Ω(werr.Entries).Should(Equal(3))
+		Ω(err).Should(MatchError(
+			"sitemap: writing index file failed after 3 entries: shortWriter error"))
+	})
+
+	t.Run("cause", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		err := WriteAll(NewDirOutput("/nonexistent/dir"), &arrayInput{})
+		var werr *WriteError
+		Ω(errors.As(err, &werr)).Should(BeTrue())
+		Ω(werr.Kind).Should(Equal(UrlsetFile))
+		Ω(err).Should(MatchError(ContainSubstring("no such file or directory")))
+	})
+
+	t.Run("context", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := WriteAllContext(ctx, &bufferOuput{}, &arrayInput{})
+		Ω(err).Should(BeIdenticalTo(context.Canceled))
+	})
+}
+
+func TestFileKind_String(t *testing.T) {
+	RegisterTestingT(t)
+
+	Ω(UrlsetFile.String()).Should(Equal("urlset"))
+	Ω(IndexFile.String()).Should(Equal("index"))
+	Ω(FileKind(0).String()).Should(Equal("FileKind(0)"))
+}
+
+func TestSentinelErrors(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Writer  Writer
+		Entries []UrlEntry
+		Limit   bool
+	}{
+		{
+			Name:    "changefreq",
+			Entries: []UrlEntry{{Loc: "one", ChangeFreq: "often"}},
+		},
+		{
+			Name:    "news",
+			Entries: []UrlEntry{{Loc: "one", News: &News{}}},
+		},
+		{
+			Name: "video",
+			Entries: []UrlEntry{{Loc: "one", Videos: []Video{
+				{ThumbnailLoc: "thumb", Title: "title"},
+			}}},
+		},
+		{
+			Name:    "images",
+			Entries: []UrlEntry{{Loc: "one", Images: make([]string, 1_001)}},
+			Limit:   true,
+		},
+		{
+			Name: "videoTags",
+			Entries: []UrlEntry{{Loc: "one", Videos: []Video{
+				{
+					ThumbnailLoc: "thumb",
+					Title:        "title",
+					Description:  "description",
+					ContentLoc:   "content",
+					Tags:         make([]string, 33),
+				},
+			}}},
+			Limit: true,
+		},
+		{
+			Name:    "fileSize",
+			Writer:  Writer{MaxFileSize: 200},
+			Entries: []UrlEntry{{Loc: strings.Repeat("a", 200)}},
+			Limit:   true,
+		},
+		{
+			Name:    "url",
+			Writer:  Writer{ValidateUrls: true},
+			Entries: []UrlEntry{{Loc: "one"}},
+		},
+		{
+			Name:   "alternates",
+			Writer: Writer{CheckAlternates: true},
+			Entries: []UrlEntry{{Loc: "one", Alternates: []Alternate{
+				{Hreflang: "fr", Href: "two"},
+			}}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			RegisterTestingT(t)
+
+			err := tc.Writer.WriteAll(&bufferOuput{}, &arrayInput{Arr: tc.Entries})
+			Ω(err).ShouldNot(BeNil())
+			Ω(errors.Is(err, ErrInvalidEntry)).Should(BeTrue())
+			Ω(errors.Is(err, ErrLimitExceeded)).Should(Equal(tc.Limit))
+			Ω(err.Error()).Should(HavePrefix(`entry "`))
+		})
+	}
+}
+
+// failAfterOutput is an output whose writers fail after the given number of
+// successful writes. The writer of the urlset file at the given index fails,
+// or the index writer if the index is negative.
+type failAfterOutput struct {
+	bufferOuput
+	FailUrlset int
+	Writes     int
+
+	nsitemaps int
+}
+
+func (o *failAfterOutput) Index() io.Writer {
+	if o.FailUrlset >= 0 {
+		return o.bufferOuput.Index()
+	}
+
+	return &shortWriter{underlying: o.bufferOuput.Index(), limit: o.Writes}
+}
+
+func (o *failAfterOutput) Urlset() io.Writer {
+	w := o.bufferOuput.Urlset()
+	o.nsitemaps++
+	if o.nsitemaps-1 != o.FailUrlset {
+		return w
+	}
+
+	return &shortWriter{underlying: w, limit: o.Writes}
+}
@@ -59,6 +59,7 @@ func ExampleWriteAll_stdout() {
 	// <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
 	//   <sitemap>
 	//     <loc>https://goiguide.com/sitemap-0.xml</loc>
+	//     <lastmod>2025-11-02T11:34:58Z</lastmod>
 	//   </sitemap>
 	// </sitemapindex>
 }
@@ -121,6 +122,7 @@ func ExampleWriteAll_buffers() {
 	// <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
 	//   <sitemap>
 	//     <loc>https://goiguide.com/sitemap-0.xml</loc>
+	//     <lastmod>2025-11-02T11:34:58Z</lastmod>
 	//   </sitemap>
 	// </sitemapindex>
 }
@@ -165,6 +167,7 @@ func ExampleWriteAll_dynamicInput() {
 	// <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
 	//   <sitemap>
 	//     <loc>https://goiguide.com/sitemap-00.xml</loc>
+	//     <lastmod>2020-11-02T11:00:00Z</lastmod>
 	//   </sitemap>
 	// </sitemapindex>
 }
@@ -0,0 +1,90 @@
+package sitemap
+
+import "time"
+
+// FallibleInput is an alternative to Input for sources which can fail to
+// produce the entries, e.g. a database cursor. Use FromFallible to write it
+// with WriteAll.
+type FallibleInput interface {
+	// Next returns the next UrlEntry to be written, or nil once there are no
+	// more items. A non-nil error means the input failed; the writing is
+	// aborted and the entry is ignored.
+	Next() (*UrlEntry, error)
+	// GetUrlsetUrl returns a URL for the Urlset file at the given index.
+	GetUrlsetUrl(idx int) string
+}
+
+// FromFallible returns an Input reading the entries from the given
+// FallibleInput. An error returned by the underlying input ends the input
+// and is reported by Err(), so WriteAll aborts with that error without
+// writing the index file.
+//
+// The returned input forwards UrlsetLastModProvider and Stopper to the
+// underlying input, if it implements them.
+func FromFallible(in FallibleInput) Input {
+	return &fallibleAdapter{underlying: in}
+}
+
+type fallibleAdapter struct {
+	underlying FallibleInput
+	err        error
+}
+
+func (in *fallibleAdapter) Next() *UrlEntry {
+	if in.err != nil {
+		return nil
+	}
+
+	entry, err := in.underlying.Next()
+	if err != nil {
+		in.err = err
+		return nil
+	}
+
+	return entry
+}
+
+func (in *fallibleAdapter) Err() error {
+	return in.err
+}
+
+func (in *fallibleAdapter) GetUrlsetUrl(idx int) string {
+	return in.underlying.GetUrlsetUrl(idx)
+}
+
+func (in *fallibleAdapter) GetUrlsetLastMod(idx int) time.Time {
+	if p, ok := in.underlying.(UrlsetLastModProvider); ok {
+		return p.GetUrlsetLastMod(idx)
+	}
+
+	return time.Time{}
+}
+
+func (in *fallibleAdapter) Stop(err error) {
+	if s, ok := in.underlying.(Stopper); ok {
+		s.Stop(err)
+	}
+}
+
+// ToFallible returns a FallibleInput reading the entries from the given
+// Input, for code written against FallibleInput. Once the input is over, the
+// error reported by the input is returned, if it implements ErrorReporter.
+func ToFallible(in Input) FallibleInput {
+	return inputAdapter{underlying: in}
+}
+
+type inputAdapter struct {
+	underlying Input
+}
+
+func (in inputAdapter) Next() (*UrlEntry, error) {
+	if entry := in.underlying.Next(); entry != nil {
+		return entry, nil
+	}
+
+	return nil, inputErr(in.underlying)
+}
+
+func (in inputAdapter) GetUrlsetUrl(idx int) string {
+	return in.underlying.GetUrlsetUrl(idx)
+}
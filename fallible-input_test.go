@@ -0,0 +1,145 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFromFallible(t *testing.T) {
+	t.Run("entries", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := FromFallible(&cursorInput{Size: 2, FailAt: -1})
+		Ω(in.Next()).Should(Equal(&UrlEntry{Loc: "http://goiguide.com/0"}))
+		Ω(in.Next()).Should(Equal(&UrlEntry{Loc: "http://goiguide.com/1"}))
+		Ω(in.Next()).Should(BeNil())
+		Ω(inputErr(in)).Should(BeNil())
+		Ω(in.GetUrlsetUrl(3)).Should(Equal("urlset 003"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		cursor := &cursorInput{Size: 5, FailAt: 1}
+		in := FromFallible(cursor)
+		Ω(in.Next()).ShouldNot(BeNil())
+		Ω(in.Next()).Should(BeNil())
+		Ω(inputErr(in)).Should(MatchError("cursor error at 1"))
+
+		// The underlying input is not read after the error.
+		Ω(in.Next()).Should(BeNil())
+		Ω(cursor.n).Should(Equal(2))
+	})
+
+	t.Run("forwarding", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		lastMod := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+		cursor := &cursorInput{FailAt: -1, LastMod: lastMod}
+		in := FromFallible(cursor)
+		Ω(in.(UrlsetLastModProvider).GetUrlsetLastMod(0)).Should(Equal(lastMod))
+
+		stopErr := errors.New("stop")
+		in.(Stopper).Stop(stopErr)
+		Ω(cursor.stopErr).Should(BeIdenticalTo(stopErr))
+	})
+}
+
+func TestToFallible(t *testing.T) {
+	t.Run("entries", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := ToFallible(&arrayInput{Arr: []UrlEntry{{Loc: "one"}}})
+		Ω(in.Next()).Should(Equal(&UrlEntry{Loc: "one"}))
+		entry, err := in.Next()
+		Ω(entry).Should(BeNil())
+		Ω(err).Should(BeNil())
+		Ω(in.GetUrlsetUrl(0)).Should(Equal("urlset no. 1"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := ToFallible(NewReader(strings.NewReader(`<urlset><url><loc>a</loc></url><url>`), nil))
+		Ω(in.Next()).ShouldNot(BeNil())
+		entry, err := in.Next()
+		Ω(entry).Should(BeNil())
+		Ω(err).Should(MatchError("XML syntax error on line 1: unexpected EOF"))
+	})
+}
+
+func TestWriteAll_FallibleInput(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var out bufferOuput
+		Ω(WriteAll(&out, FromFallible(&cursorInput{Size: 50_000 + 3, FailAt: -1}))).
+			Should(BeNil())
+		assertOutput(&out, 50_000+3)
+	})
+
+	for _, workers := range []int{0, 2} {
+		workers := workers
+		t.Run(fmt.Sprintf("errorWorkers%d", workers), func(t *testing.T) {
+			RegisterTestingT(t)
+
+			w := Writer{Workers: workers}
+			var out bufferOuput
+			in := FromFallible(&cursorInput{Size: 50_000 * 3, FailAt: 50_000 + 10})
+			Ω(w.WriteAll(&out, in)).Should(MatchError("cursor error at 50010"))
+			Ω(out.index.Len()).Should(Equal(0))
+		})
+	}
+
+	t.Run("errorAtFileBoundary", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		// The input fails right after a complete urlset file.
+		var out bufferOuput
+		in := FromFallible(&cursorInput{Size: 50_000 * 2, FailAt: 50_000})
+		Ω(WriteAll(&out, in)).Should(MatchError("cursor error at 50000"))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+}
+
+// cursorInput is a FallibleInput returning entries with locations like
+// "http://goiguide.com/N", which fails when reading the entry at FailAt.
+type cursorInput struct {
+	Size    int
+	FailAt  int
+	LastMod time.Time
+
+	n       int
+	stopErr error
+}
+
+func (in *cursorInput) Next() (*UrlEntry, error) {
+	if in.n == in.FailAt {
+		in.n++
+		return nil, fmt.Errorf("cursor error at %d", in.FailAt)
+	}
+	if in.n >= in.Size {
+		return nil, nil
+	}
+
+	entry := &UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%d", in.n)}
+	in.n++
+	return entry, nil
+}
+
+func (in *cursorInput) GetUrlsetUrl(idx int) string {
+	return fmt.Sprintf("urlset %03d", idx)
+}
+
+func (in *cursorInput) GetUrlsetLastMod(int) time.Time {
+	return in.LastMod
+}
+
+func (in *cursorInput) Stop(err error) {
+	in.stopErr = err
+}
@@ -0,0 +1,178 @@
+package sitemap
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// GzipOutput is an Output adapter compressing every file with gzip before
+// writing it to the underlying output.
+//
+// The writers returned by GzipOutput implement Finalizer, so WriteAll
+// finalizes the gzip stream of every file once it is complete. If the
+// underlying writer implements Finalizer too, it is finalized right after
+// the gzip stream.
+//
+// The file size limit of the protocol applies to the uncompressed size of
+// a file, which is what Writer.MaxFileSize is checked against. Hence, no
+// extra configuration is needed to produce valid compressed files.
+//
+// When the files are written in parallel, see Writer.Workers, they are
+// compressed by the workers too.
+type GzipOutput struct {
+	underlying Output
+	level      int
+	zw         gzipWriter
+	// active is set when zw holds a stream that is not finalized yet
+	active bool
+}
+
+// NewGzipOutput returns a GzipOutput writing to the given output with the
+// given compression level, e.g. gzip.DefaultCompression or
+// gzip.BestCompression.
+func NewGzipOutput(o Output, level int) (*GzipOutput, error) {
+	zw, err := gzip.NewWriterLevel(io.Discard, level)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &GzipOutput{underlying: o, level: level}
+	out.zw = gzipWriter{Writer: zw, out: out}
+	return out, nil
+}
+
+func (o *GzipOutput) Index() io.Writer {
+	return o.next(o.underlying.Index)
+}
+
+func (o *GzipOutput) Urlset() io.Writer {
+	return o.next(o.underlying.Urlset)
+}
+
+// SkipUrlset keeps the previous urlset file if the underlying output
+// implements UrlsetSkipper and keeps it.
+func (o *GzipOutput) SkipUrlset() bool {
+	if s, ok := o.underlying.(UrlsetSkipper); ok {
+		return s.SkipUrlset()
+	}
+
+	return false
+}
+
+// GetUrlsetUrl returns the URL of the urlset file at the given index provided
+// by the underlying output, if it implements UrlsetUrlProvider.
+func (o *GzipOutput) GetUrlsetUrl(idx int) string {
+	if p, ok := o.underlying.(UrlsetUrlProvider); ok {
+		return p.GetUrlsetUrl(idx)
+	}
+
+	return ""
+}
+
+// Section returns a GzipOutput writing the urlset files of the named section
+// to the output of the section provided by the underlying output, see
+// SectionOutput. It returns nil if the underlying output does not implement
+// SectionOutput or does not provide a separate output for the section.
+func (o *GzipOutput) Section(name string) Output {
+	p, ok := o.underlying.(SectionOutput)
+	if !ok {
+		return nil
+	}
+	section := p.Section(name)
+	if section == nil {
+		return nil
+	}
+
+	// The level is validated by NewGzipOutput.
+	out, _ := NewGzipOutput(section, o.level)
+	return out
+}
+
+// GetIndexUrl returns the URL of the index file at the given index provided
+// by the underlying output, if it implements IndexUrlProvider.
+func (o *GzipOutput) GetIndexUrl(idx int) string {
+	if p, ok := o.underlying.(IndexUrlProvider); ok {
+		return p.GetIndexUrl(idx)
+	}
+
+	return ""
+}
+
+// Close finalizes the last requested file, unless it is finalized already.
+// It is only needed when the files are not written by WriteAll.
+func (o *GzipOutput) Close() error {
+	if !o.active {
+		return nil
+	}
+
+	o.active = false
+	if err := o.zw.Close(); err != nil {
+		return err
+	}
+
+	return finalize(o.zw.underlying)
+}
+
+func (o *GzipOutput) next(getWriter func() io.Writer) io.Writer {
+	// The previous file is complete once the next one is requested.
+	// Report a failure to finalize it when the new file is written, this
+	// way the writing aborts with the error.
+	if err := o.Close(); err != nil {
+		return errWriter{err: err}
+	}
+
+	o.zw.underlying = getWriter()
+	o.zw.Reset(o.zw.underlying)
+	o.active = true
+	return &o.zw
+}
+
+// newCompressor returns a gzip compressor used by a parallel writing worker.
+func (o *GzipOutput) newCompressor() compressor {
+	// The level is validated by NewGzipOutput.
+	zw, _ := gzip.NewWriterLevel(io.Discard, o.level)
+	return gzipCompressor{zw: zw}
+}
+
+// compressedUrlset returns a writer of the underlying output, the file is
+// compressed by a parallel writing worker.
+func (o *GzipOutput) compressedUrlset() io.Writer {
+	if err := o.Close(); err != nil {
+		return errWriter{err: err}
+	}
+
+	return o.underlying.Urlset()
+}
+
+type gzipCompressor struct {
+	zw *gzip.Writer
+}
+
+func (c gzipCompressor) compress(dst io.Writer, src []byte) error {
+	c.zw.Reset(dst)
+	if _, err := c.zw.Write(src); err != nil {
+		return err
+	}
+
+	return c.zw.Close()
+}
+
+// gzipWriter is a gzip stream of a single file.
+type gzipWriter struct {
+	*gzip.Writer
+	underlying io.Writer
+	out        *GzipOutput
+}
+
+func (w *gzipWriter) Finalize() error {
+	return w.out.Close()
+}
+
+// errWriter is a writer failing every write with the given error.
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
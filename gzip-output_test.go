@@ -0,0 +1,250 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGzipOutput(t *testing.T) {
+	customEntry := func(idx int) *UrlEntry {
+		return &UrlEntry{
+			Loc: fmt.Sprintf("http://goiguide.com/%d", idx),
+		}
+	}
+	customUrl := func(idx int) string {
+		return fmt.Sprintf("urlset %03d", idx)
+	}
+
+	t.Run("simple", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size:            3,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		var out bufferOuput
+		gzOut, err := NewGzipOutput(&out, gzip.BestCompression)
+		Ω(err).Should(BeNil())
+
+		Ω(WriteAll(gzOut, &in)).Should(BeNil())
+		Ω(gzOut.Close()).Should(BeNil())
+		Ω(gzOut.Close()).Should(BeNil())
+
+		Ω(gunzip(&out.index)).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>urlset 000</loc>
+  </sitemap>
+</sitemapindex>
+		`)))
+
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(gunzip(&out.sitemaps[0])).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>http://goiguide.com/0</loc>
+  </url>
+  <url>
+    <loc>http://goiguide.com/1</loc>
+  </url>
+  <url>
+    <loc>http://goiguide.com/2</loc>
+  </url>
+</urlset>
+		`)))
+	})
+
+	t.Run("multipleFiles", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size:            50_000*2 + 321,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		var out bufferOuput
+		gzOut, err := NewGzipOutput(&out, gzip.DefaultCompression)
+		Ω(err).Should(BeNil())
+
+		Ω(WriteAll(gzOut, &in)).Should(BeNil())
+		Ω(gzOut.Close()).Should(BeNil())
+
+		var plain bufferOuput
+		plain.index.WriteString(gunzip(&out.index))
+		for i := range out.sitemaps {
+			Ω(out.sitemaps[i].Len()).Should(BeNumerically("<", maxSitemapSize))
+			plain.sitemaps = append(plain.sitemaps, bytes.Buffer{})
+			plain.sitemaps[i].WriteString(gunzip(&out.sitemaps[i]))
+		}
+		assertOutput(&plain, in.Size)
+	})
+
+	t.Run("finalize", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size:            50_000 + 1,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		var out finalizingOutput
+		gzOut, err := NewGzipOutput(&out, gzip.DefaultCompression)
+		Ω(err).Should(BeNil())
+
+		Ω(WriteAll(gzOut, &in)).Should(BeNil())
+		Ω(out.finalized).Should(Equal([]string{"urlset 0", "urlset 1", "index"}))
+		Ω(gzOut.Close()).Should(BeNil())
+		Ω(out.finalized).Should(HaveLen(3))
+
+		Ω(gunzip(&out.index)).Should(HaveSuffix("</sitemapindex>"))
+		Ω(out.sitemaps).Should(HaveLen(2))
+		Ω(gunzip(&out.sitemaps[0])).Should(HaveSuffix("</urlset>"))
+		Ω(gunzip(&out.sitemaps[1])).Should(HaveSuffix("</urlset>"))
+	})
+
+	t.Run("urlsetUrl", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		gzOut, err := NewGzipOutput(&bufferOuput{}, gzip.DefaultCompression)
+		Ω(err).Should(BeNil())
+		Ω(gzOut.GetUrlsetUrl(3)).Should(Equal(""))
+
+		dirOut := NewDirOutput(t.TempDir())
+		dirOut.BaseUrl = "https://goiguide.com/"
+		gzOut, err = NewGzipOutput(dirOut, gzip.DefaultCompression)
+		Ω(err).Should(BeNil())
+		Ω(gzOut.GetUrlsetUrl(3)).Should(Equal("https://goiguide.com/sitemap-3.xml"))
+	})
+
+	t.Run("sections", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		gzOut, err := NewGzipOutput(&bufferOuput{}, gzip.DefaultCompression)
+		Ω(err).Should(BeNil())
+		Ω(gzOut.Section("blog")).Should(BeNil())
+
+		out := sectionsOutput{}
+		gzOut, err = NewGzipOutput(&out, gzip.DefaultCompression)
+		Ω(err).Should(BeNil())
+		in := dynamicInput{Size: 2, CustomEntry: customEntry, CustomUrlsetUrl: customUrl}
+		_, err = new(Writer).WriteSections(context.Background(), gzOut, []Section{
+			{Name: "blog", Input: &in},
+		})
+		Ω(err).Should(BeNil())
+
+		Ω(out.sitemaps).Should(BeEmpty())
+		Ω(out.sections["blog"].sitemaps).Should(HaveLen(1))
+		Ω(gunzip(&out.sections["blog"].sitemaps[0])).
+			Should(ContainSubstring("<loc>http://goiguide.com/1</loc>"))
+		Ω(gunzip(&out.index)).Should(ContainSubstring("<loc>urlset 000</loc>"))
+	})
+
+	t.Run("invalidLevel", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		gzOut, err := NewGzipOutput(&bufferOuput{}, 42)
+		Ω(err).Should(MatchError("gzip: invalid compression level: 42"))
+		Ω(gzOut).Should(BeNil())
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Run("urlset", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := dynamicInput{
+				Size:            3,
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			gzOut, err := NewGzipOutput(&failiingOutput{FailUrlset: true},
+				gzip.DefaultCompression)
+			Ω(err).Should(BeNil())
+
+			Ω(WriteAll(gzOut, &in)).Should(MatchError(
+				"sitemap: writing urlset file 0 failed after 0 entries: failingWriter error"))
+		})
+
+		t.Run("index", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := dynamicInput{
+				Size:            3,
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			gzOut, err := NewGzipOutput(&failiingOutput{FailIndex: true},
+				gzip.DefaultCompression)
+			Ω(err).Should(BeNil())
+
+			Ω(WriteAll(gzOut, &in)).Should(MatchError(
+				"sitemap: writing index file failed after 0 entries: failingWriter error"))
+		})
+
+		t.Run("finalizeUrlset", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := dynamicInput{
+				Size:            3,
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			// The gzip header is written right away, while the compressed
+			// data is flushed only when the stream is finalized.
+			out := shortOutput{UrlsetLimit: 1}
+			gzOut, err := NewGzipOutput(&out, gzip.DefaultCompression)
+			Ω(err).Should(BeNil())
+
+			Ω(WriteAll(gzOut, &in)).Should(MatchError(
+				"sitemap: writing urlset file 0 failed after 3 entries: shortWriter error"))
+			Ω(out.index.Len()).Should(Equal(0))
+		})
+	})
+}
+
+// shortOutput is an output whose urlset writers fail after the given number
+// of successful writes.
+type shortOutput struct {
+	bufferOuput
+	UrlsetLimit int
+}
+
+func (o *shortOutput) Urlset() io.Writer {
+	return &shortWriter{
+		underlying: o.bufferOuput.Urlset(),
+		limit:      o.UrlsetLimit,
+	}
+}
+
+type shortWriter struct {
+	underlying io.Writer
+	limit      int
+}
+
+func (w *shortWriter) Write(bs []byte) (int, error) {
+	if w.limit <= 0 {
+		return 0, errors.New("shortWriter error")
+	}
+
+	w.limit--
+	return w.underlying.Write(bs)
+}
+
+func gunzip(r io.Reader) string {
+	zr, err := gzip.NewReader(r)
+	Ω(err).Should(BeNil())
+
+	bs, err := io.ReadAll(zr)
+	Ω(err).Should(BeNil())
+	return string(bs)
+}
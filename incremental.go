@@ -0,0 +1,148 @@
+package sitemap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Manifest describes the urlset files written by Writer.WriteIncremental.
+// It is meant to be stored, e.g. encoded as JSON, and passed to the next run.
+type Manifest struct {
+	Urlsets []ManifestUrlset `json:"urlsets"`
+}
+
+// ManifestUrlset describes a single urlset file of a Manifest.
+type ManifestUrlset struct {
+	// FirstLoc and LastLoc are the locations of the first and the last
+	// entries in the file, Entries is the number of entries. The next run
+	// ends a file at LastLoc, see Writer.WriteIncremental.
+	FirstLoc string `json:"firstLoc"`
+	LastLoc  string `json:"lastLoc"`
+	Entries  int    `json:"entries"`
+	// Hash is the hex-encoded SHA-256 hash of the uncompressed file.
+	Hash string `json:"hash"`
+	// LastMod is the lastmod value of the file listed in the index file.
+	LastMod time.Time `json:"lastmod"`
+}
+
+// WriteIncremental writes all files to the given output like WriteAllContext,
+// reusing the urlset files of the previous run described by the given
+// manifest. The returned manifest describes the files of this run.
+//
+// The files keep the entry ranges of the previous run: a file ends at an
+// entry listed as the last one of a file by the manifest, unless the file is
+// full before. Hence inserting or removing entries changes the files holding
+// them only, as long as the files of the previous run are not full; an entry
+// inserted into a full file moves the following entries to the next files.
+//
+// Every urlset file is rendered in memory and compared with the file at the
+// same index of the previous run. An unchanged file keeps its lastmod value
+// in the index file and, if the output implements UrlsetSkipper, is not
+// written again. Changed and new files are written, and listed in the index
+// file with the time of the run as lastmod. A lastmod value provided by
+// UrlsetLastModProvider takes precedence in both cases.
+//
+// A nil manifest means there is no previous run, in which case all files are
+// written. The files are written sequentially, Writer.Workers is ignored.
+func (w *Writer) WriteIncremental(
+	ctx context.Context,
+	o Output,
+	in Input,
+	prev *Manifest,
+) (_ *Manifest, err error) {
+	defer func() { stopInput(in, err) }()
+
+	if err := checkNamespaces(w.Namespaces); err != nil {
+		return nil, err
+	}
+	if prev == nil {
+		prev = &Manifest{}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	manifest := &Manifest{}
+	s := w.newSitemapWriter(in, o)
+	s.boundaries = make(map[string]bool, len(prev.Urlsets))
+	for _, f := range prev.Urlsets {
+		if f.LastLoc != "" {
+			s.boundaries[f.LastLoc] = true
+		}
+	}
+	files, err := s.writeUrlsets(ctx, o, in,
+		func(idx int, prevEntry *UrlEntry) (urlsetInfo, *UrlEntry, error) {
+			var prevFile *ManifestUrlset
+			if idx < len(prev.Urlsets) {
+				prevFile = &prev.Urlsets[idx]
+			}
+
+			info, co, file, err := s.writeUrlsetIncremental(ctx, o, in, idx, prevEntry, prevFile)
+			if err != nil {
+				return urlsetInfo{}, nil, err
+			}
+			if prevFile == nil || file.Hash != prevFile.Hash {
+				info.lastMod = now
+			}
+
+			manifest.Urlsets = append(manifest.Urlsets, file)
+			return info, co, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.writeIndex(ctx, in, o, files); err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		manifest.Urlsets[i].LastMod = files[i].lastMod
+	}
+	return manifest, nil
+}
+
+// writeUrlsetIncremental writes the urlset file at the given index, unless
+// it is identical to the given file of the previous run and the output keeps
+// that one.
+func (s *sitemapWriter) writeUrlsetIncremental(
+	ctx context.Context,
+	o Output,
+	in Input,
+	idx int,
+	prevEntry *UrlEntry,
+	prevFile *ManifestUrlset,
+) (urlsetInfo, *UrlEntry, ManifestUrlset, error) {
+	s.fileBuf.Reset()
+	info, co, err := s.writeUrlsetFile(ctx, &s.fileBuf, in, prevEntry)
+	if err != nil {
+		return urlsetInfo{}, nil, ManifestUrlset{}, err
+	}
+
+	sum := sha256.Sum256(s.fileBuf.Bytes())
+	file := ManifestUrlset{
+		FirstLoc: info.firstLoc,
+		LastLoc:  info.lastLoc,
+		Entries:  info.entries,
+		Hash:     hex.EncodeToString(sum[:]),
+	}
+
+	if prevFile != nil && file.Hash == prevFile.Hash {
+		info.lastMod = prevFile.LastMod
+		if skipper, ok := o.(UrlsetSkipper); ok && skipper.SkipUrlset() {
+			return info, co, file, nil
+		}
+	}
+
+	urlsetWriter := o.Urlset()
+	if _, err := urlsetWriter.Write(s.fileBuf.Bytes()); err != nil {
+		return urlsetInfo{}, nil, ManifestUrlset{},
+			&WriteError{Kind: UrlsetFile, File: idx, Err: err}
+	}
+	if err := finalize(urlsetWriter); err != nil {
+		return urlsetInfo{}, nil, ManifestUrlset{},
+			&WriteError{Kind: UrlsetFile, File: idx, Entries: info.entries, Err: err}
+	}
+
+	return info, co, file, nil
+}
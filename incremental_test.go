@@ -0,0 +1,229 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWriter_WriteIncremental(t *testing.T) {
+	entries := func(changed ...int) []UrlEntry {
+		arr := make([]UrlEntry, 6)
+		for i := range arr {
+			arr[i].Loc = fmt.Sprintf("http://goiguide.com/%d", i)
+		}
+		for _, i := range changed {
+			arr[i].Loc = "http://goiguide.com/c"
+		}
+		return arr
+	}
+	// Every urlset file holds 2 entries.
+	w := Writer{MaxFileSize: 240}
+	prevLastMod := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	t.Run("noManifest", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		start := time.Now().Truncate(time.Second)
+		var out skippingOutput
+		m, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: entries()}, nil)
+		Ω(err).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(3))
+		Ω(out.skipped).Should(Equal(0))
+
+		Ω(m.Urlsets).Should(HaveLen(3))
+		for i, f := range m.Urlsets {
+			Ω(f.FirstLoc).Should(Equal(fmt.Sprintf("http://goiguide.com/%d", 2*i)))
+			Ω(f.LastLoc).Should(Equal(fmt.Sprintf("http://goiguide.com/%d", 2*i+1)))
+			Ω(f.Entries).Should(Equal(2))
+			Ω(f.Hash).Should(HaveLen(64))
+			Ω(f.LastMod).ShouldNot(BeTemporally("<", start))
+			Ω(out.index.String()).Should(ContainSubstring(
+				"<lastmod>" + f.LastMod.Format(time.RFC3339) + "</lastmod>"))
+		}
+		Ω(m.Urlsets[0].Hash).ShouldNot(Equal(m.Urlsets[1].Hash))
+	})
+
+	t.Run("changedFile", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var out skippingOutput
+		prev, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: entries()}, nil)
+		Ω(err).Should(BeNil())
+		for i := range prev.Urlsets {
+			prev.Urlsets[i].LastMod = prevLastMod
+		}
+
+		start := time.Now().Truncate(time.Second)
+		out = skippingOutput{}
+		m, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: entries(3)}, prev)
+		Ω(err).Should(BeNil())
+		Ω(out.skipped).Should(Equal(2))
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(out.sitemaps[0].String()).Should(ContainSubstring("http://goiguide.com/c"))
+
+		Ω(m.Urlsets).Should(HaveLen(3))
+		Ω(m.Urlsets[0]).Should(Equal(prev.Urlsets[0]))
+		Ω(m.Urlsets[2]).Should(Equal(prev.Urlsets[2]))
+		Ω(m.Urlsets[1].Hash).ShouldNot(Equal(prev.Urlsets[1].Hash))
+		Ω(m.Urlsets[1].LastMod).ShouldNot(BeTemporally("<", start))
+		Ω(strings.Count(out.index.String(), "<lastmod>2021-03-04T05:06:07Z</lastmod>")).
+			Should(Equal(2))
+	})
+
+	t.Run("moreFiles", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var out skippingOutput
+		prev, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: entries()[:4]}, nil)
+		Ω(err).Should(BeNil())
+		Ω(prev.Urlsets).Should(HaveLen(2))
+
+		out = skippingOutput{}
+		m, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: entries()}, prev)
+		Ω(err).Should(BeNil())
+		Ω(out.skipped).Should(Equal(2))
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(m.Urlsets).Should(HaveLen(3))
+		Ω(m.Urlsets[2].FirstLoc).Should(Equal("http://goiguide.com/4"))
+	})
+
+	t.Run("keepRanges", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		locs := func(skip int, insert string) []UrlEntry {
+			var arr []UrlEntry
+			for i := 0; i < 30; i++ {
+				if i == 3 && insert != "" {
+					arr = append(arr, UrlEntry{Loc: insert})
+				}
+				if i != skip {
+					arr = append(arr, UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%d", i)})
+				}
+			}
+			return arr
+		}
+		w := Writer{MaxEntries: 10}
+
+		var out skippingOutput
+		prev, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: locs(-1, "")}, nil)
+		Ω(err).Should(BeNil())
+		Ω(prev.Urlsets).Should(HaveLen(3))
+
+		// The removed entry does not move the following ones to file 0.
+		out = skippingOutput{}
+		m, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: locs(5, "")}, prev)
+		Ω(err).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(out.skipped).Should(Equal(2))
+		Ω(m.Urlsets[0].Entries).Should(Equal(9))
+		Ω(m.Urlsets[0].LastLoc).Should(Equal("http://goiguide.com/9"))
+		Ω(m.Urlsets[1:]).Should(Equal(prev.Urlsets[1:]))
+
+		// The inserted entry does not move the following ones to file 1.
+		prev = m
+		out = skippingOutput{}
+		m, err = w.WriteIncremental(context.Background(), &out,
+			&arrayInput{Arr: locs(5, "http://goiguide.com/new")}, prev)
+		Ω(err).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(out.sitemaps[0].String()).Should(ContainSubstring("http://goiguide.com/new"))
+		Ω(out.skipped).Should(Equal(2))
+		Ω(m.Urlsets[0].Entries).Should(Equal(10))
+		Ω(m.Urlsets[1:]).Should(Equal(prev.Urlsets[1:]))
+	})
+
+	t.Run("cannotSkip", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var out bufferOuput
+		prev, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: entries()}, nil)
+		Ω(err).Should(BeNil())
+		for i := range prev.Urlsets {
+			prev.Urlsets[i].LastMod = prevLastMod
+		}
+
+		out = bufferOuput{}
+		m, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: entries()}, prev)
+		Ω(err).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(3))
+		Ω(m).Should(Equal(prev))
+	})
+
+	t.Run("lastModProvider", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := lastModInput{
+			arrayInput: arrayInput{Arr: entries()},
+			LastMods:   map[int]time.Time{1: prevLastMod},
+		}
+		var out skippingOutput
+		m, err := w.WriteIncremental(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(m.Urlsets[0].LastMod).ShouldNot(Equal(prevLastMod))
+		Ω(m.Urlsets[1].LastMod).Should(Equal(prevLastMod))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		out := failiingOutput{FailUrlset: true}
+		m, err := w.WriteIncremental(context.Background(), &out, &arrayInput{Arr: entries()}, nil)
+		Ω(err).Should(MatchError(
+			"sitemap: writing urlset file 0 failed after 0 entries: failingWriter error"))
+		Ω(m).Should(BeNil())
+	})
+
+	t.Run("dirOutput", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		dir := t.TempDir()
+		out := NewDirOutput(dir)
+		prev, err := w.WriteIncremental(context.Background(), out, &arrayInput{Arr: entries()}, nil)
+		Ω(err).Should(BeNil())
+		Ω(out.Commit()).Should(BeNil())
+		sitemap0 := readFile(dir, "sitemap-0.xml")
+
+		// The manifest survives a JSON round trip.
+		bs, err := json.Marshal(prev)
+		Ω(err).Should(BeNil())
+		prev = nil
+		Ω(json.Unmarshal(bs, &prev)).Should(BeNil())
+
+		// A missing file is written again, even though it did not change.
+		Ω(os.Remove(filepath.Join(dir, "sitemap-2.xml"))).Should(BeNil())
+
+		m, err := w.WriteIncremental(context.Background(), out, &arrayInput{Arr: entries(3)}, prev)
+		Ω(err).Should(BeNil())
+		Ω(out.Commit()).Should(BeNil())
+		Ω(listDir(dir)).Should(Equal([]string{
+			"sitemap-0.xml",
+			"sitemap-1.xml",
+			"sitemap-2.xml",
+			"sitemap.xml",
+		}))
+		Ω(readFile(dir, "sitemap-0.xml")).Should(Equal(sitemap0))
+		Ω(readFile(dir, "sitemap-1.xml")).Should(ContainSubstring("http://goiguide.com/c"))
+		Ω(readFile(dir, "sitemap-2.xml")).Should(ContainSubstring("http://goiguide.com/5"))
+		Ω(m.Urlsets[0].LastMod.Equal(prev.Urlsets[0].LastMod)).Should(BeTrue())
+		Ω(m.Urlsets[2].LastMod.Equal(prev.Urlsets[2].LastMod)).Should(BeTrue())
+	})
+}
+
+// skippingOutput keeps every urlset file it is asked to skip.
+type skippingOutput struct {
+	bufferOuput
+	skipped int
+}
+
+func (o *skippingOutput) SkipUrlset() bool {
+	o.skipped++
+	return true
+}
@@ -7,19 +7,243 @@ import (
 
 type Input interface {
 	// Next returns the next UrlEntry to be written. The function should
-	// return nil if and only if there are no more items.
+	// return nil if and only if there are no more items. An input which can
+	// fail should implement ErrorReporter, see also FallibleInput.
 	Next() *UrlEntry
 	// GetUrlsetUrl returns a URL for the Urlset file at the given index.
 	GetUrlsetUrl(idx int) string
 }
 
+// UrlsetLastModProvider is an optional interface an Input can implement to
+// override the lastmod value of a urlset file in the index file. By default
+// the latest LastMod of the entries written into the file is used.
+type UrlsetLastModProvider interface {
+	// GetUrlsetLastMod returns the lastmod value for the Urlset file at the
+	// given index. The zero time means the default value should be used.
+	GetUrlsetLastMod(idx int) time.Time
+}
+
+// ErrorReporter is an optional interface an Input can implement to report
+// that it failed to produce all entries. Err is called once Next() returns
+// nil; a non-nil error aborts the writing before the current urlset file is
+// completed and the index file is written, and is returned as is.
+type ErrorReporter interface {
+	Err() error
+}
+
+// Stopper is an optional interface an Input can implement to be notified
+// once the writing stops reading it, e.g. to release a producer blocked on
+// the input. Stop is called exactly once when the writing returns, with the
+// error it fails with, or nil on success.
+type Stopper interface {
+	Stop(err error)
+}
+
 type UrlEntry struct {
-	Loc     string
-	LastMod time.Time
-	Images  []string
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq ChangeFreq
+	Priority   Priority
+	// Alternates lists the alternate language versions of the page,
+	// written as <xhtml:link rel="alternate"> elements.
+	Alternates []Alternate
+	// Images lists image URLs, written without any metadata.
+	Images []string
+	// ImageDetails lists images with their metadata, written after Images.
+	// An entry is limited to 1,000 images in total.
+	ImageDetails []Image
+	// News marks the entry as a news article, see News.
+	News   *News
+	Videos []Video
+	// Extensions are written at the end of the <url> element, see Extension.
+	Extensions []Extension
+}
+
+// Extension is an XML element of a sitemap extension the package does not
+// support, written into the <url> element of an entry.
+//
+// The namespace of the extension is declared by the header of every urlset
+// file containing an entry using it, the same way as the namespaces of the
+// supported extensions. Within a file, a namespace prefix has to be bound to
+// a single URI, the writing fails otherwise.
+//
+// When urlset files are written in parallel, see Writer.Workers, the
+// extensions are written after the input returns the following entries,
+// hence an Input must not reuse them.
+type Extension interface {
+	// Namespace returns the namespace of the elements of the extension.
+	Namespace() Namespace
+	// WriteXml writes the elements of the extension using the prefix of the
+	// namespace. The values have to be escaped, e.g. with xml.EscapeText.
+	// An error fails the writing as an invalid entry.
+	WriteXml(w io.Writer) error
+}
+
+// Namespace is an XML namespace declaration, e.g. the prefix "xhtml" for
+// the URI "http://www.w3.org/1999/xhtml".
+type Namespace struct {
+	Prefix string
+	URI    string
+}
+
+// Alternate is an alternate language version of a page.
+type Alternate struct {
+	// Hreflang is the language code of the version, e.g. "en", "fr-CA" or
+	// "x-default".
+	Hreflang string
+	Href     string
+}
+
+// Image is an image on the page, written using the Google image sitemap
+// extension. Loc is required, the rest of the fields are omitted when empty.
+type Image struct {
+	Loc         string
+	Caption     string
+	Title       string
+	GeoLocation string
+	License     string
+}
+
+// News holds the metadata of a news article, written using the Google News
+// sitemap extension. Urlset files containing news articles are limited to
+// 1,000 entries.
+type News struct {
+	Publication     NewsPublication
+	PublicationDate time.Time
+	Title           string
+}
+
+// Video holds the metadata of a video on the page, written using the Google
+// video sitemap extension.
+type Video struct {
+	// ThumbnailLoc, Title and Description are required.
+	ThumbnailLoc string
+	Title        string
+	// Description is limited to 2,048 characters.
+	Description string
+	// At least one of ContentLoc (the media file) and PlayerLoc (the player)
+	// is required.
+	ContentLoc string
+	PlayerLoc  string
+	// Duration is optional, between 1 second and 8 hours. It is written in
+	// whole seconds.
+	Duration        time.Duration
+	ExpirationDate  time.Time
+	PublicationDate time.Time
+	// NotFamilyFriendly marks the video as available only with SafeSearch
+	// turned off.
+	NotFamilyFriendly    bool
+	RequiresSubscription bool
+	Live                 bool
+	Uploader             string
+	// Tags are limited to 32 per video.
+	Tags []string
+}
+
+// NewsPublication identifies the publication a news article belongs to.
+type NewsPublication struct {
+	Name string
+	// Language is an ISO 639 language code, e.g. "en" or "zh-cn".
+	Language string
+}
+
+// ChangeFreq is a hint of how frequently the page is likely to change.
+// The empty value is omitted from the output.
+type ChangeFreq string
+
+const (
+	ChangeFreqAlways  ChangeFreq = "always"
+	ChangeFreqHourly  ChangeFreq = "hourly"
+	ChangeFreqDaily   ChangeFreq = "daily"
+	ChangeFreqWeekly  ChangeFreq = "weekly"
+	ChangeFreqMonthly ChangeFreq = "monthly"
+	ChangeFreqYearly  ChangeFreq = "yearly"
+	ChangeFreqNever   ChangeFreq = "never"
+)
+
+// IsValid reports whether f is one of the values defined by the protocol.
+// The empty value is valid, it means the field is not set.
+func (f ChangeFreq) IsValid() bool {
+	switch f {
+	case "", ChangeFreqAlways, ChangeFreqHourly, ChangeFreqDaily,
+		ChangeFreqWeekly, ChangeFreqMonthly, ChangeFreqYearly, ChangeFreqNever:
+		return true
+	default:
+		return false
+	}
+}
+
+// Priority is the priority of a URL relative to other URLs on the site,
+// a value between 0.0 and 1.0. The zero value means the priority is not set
+// and is omitted from the output. Use NewPriority to create a set value.
+type Priority struct {
+	value float64
+	set   bool
+}
+
+// NewPriority returns a priority set to the given value.
+func NewPriority(v float64) Priority {
+	return Priority{value: v, set: true}
+}
+
+// Value returns the priority value and whether it is set.
+func (p Priority) Value() (float64, bool) {
+	return p.value, p.set
+}
+
+// IsValid reports whether p is either not set or set to a value between
+// 0.0 and 1.0.
+func (p Priority) IsValid() bool {
+	return !p.set || (p.value >= 0 && p.value <= 1)
 }
 
 type Output interface {
 	Index() io.Writer
 	Urlset() io.Writer
 }
+
+// UrlsetUrlProvider is an optional interface an Output can implement to
+// provide the public URL of the urlset files it writes. It is consulted when
+// Input.GetUrlsetUrl() returns an empty string.
+type UrlsetUrlProvider interface {
+	// GetUrlsetUrl returns a URL for the Urlset file at the given index.
+	GetUrlsetUrl(idx int) string
+}
+
+// IndexUrlProvider is an optional interface an Input or an Output can
+// implement to provide the public URL of the index files, reported by
+// Writer.WriteAllResult. There is more than one index file if the urlset
+// files do not fit into a single one.
+type IndexUrlProvider interface {
+	// GetIndexUrl returns a URL for the index file at the given index.
+	GetIndexUrl(idx int) string
+}
+
+// SectionOutput is an optional interface an Output can implement to write
+// the urlset files of every section separately, see Writer.WriteSections.
+type SectionOutput interface {
+	// Section returns the output of the urlset files of the named section.
+	// Its Index() is not used. It returns nil if the files of the section
+	// are written to the output itself, along with the other sections.
+	Section(name string) Output
+}
+
+// UrlsetSkipper is an optional interface an Output can implement to keep the
+// urlset files of a previous run that did not change, see
+// Writer.WriteIncremental.
+type UrlsetSkipper interface {
+	// SkipUrlset is called instead of Urlset() for a file identical to the
+	// file written at the same index by the previous run. It returns false
+	// if the previous file cannot be kept, in which case Urlset() is called
+	// to write the file again.
+	SkipUrlset() bool
+}
+
+// Finalizer is an optional interface a writer returned by Output can
+// implement to be notified once the file is complete, e.g. to close a file
+// handle or commit an upload. Finalize is called right after the footer of
+// the file is written; it is not called if writing the file fails.
+// An error returned by Finalize aborts the writing.
+type Finalizer interface {
+	Finalize() error
+}
@@ -0,0 +1,393 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// urlsetChunk is a part of the input encoded by a single worker. A chunk is
+// written into one or more urlset files, depending on their size.
+type urlsetChunk struct {
+	// seq is the position of the chunk in the input
+	seq int
+	// entries holds copies of the input entries
+	entries []chunkEntry
+	// nextIdx is the index of the next entry returned by Next()
+	nextIdx int
+
+	// files holds the encoded files, only the first nfiles are valid
+	files  []*chunkFile
+	nfiles int
+	err    error
+	// invalid holds the invalid entries skipped by the worker
+	invalid []*EntryError
+}
+
+// chunkEntry is a copy of an input entry.
+type chunkEntry struct {
+	UrlEntry
+	// news holds the copy of the entry news
+	news News
+	// skipped is set if the entry is skipped as invalid
+	skipped bool
+}
+
+// chunkFile is an encoded urlset file.
+type chunkFile struct {
+	buf  bytes.Buffer
+	info urlsetInfo
+}
+
+// add appends a copy of the given entry to the chunk, reusing the memory of
+// the entries the chunk held before.
+func (c *urlsetChunk) add(e *UrlEntry) {
+	n := len(c.entries)
+	if n < cap(c.entries) {
+		c.entries = c.entries[:n+1]
+	} else {
+		c.entries = append(c.entries, chunkEntry{})
+	}
+
+	copyUrlEntry(&c.entries[n].UrlEntry, &c.entries[n].news, e)
+	c.entries[n].skipped = false
+}
+
+func (c *urlsetChunk) reset(seq int) {
+	c.seq = seq
+	c.entries = c.entries[:0]
+	c.nextIdx = 0
+	c.nfiles = 0
+	c.err = nil
+	c.invalid = c.invalid[:0]
+}
+
+// Next implements Input, so the chunk can be written by writeUrlsetFile.
+func (c *urlsetChunk) Next() *UrlEntry {
+	if c.nextIdx >= len(c.entries) {
+		return nil
+	}
+
+	c.nextIdx++
+	return &c.entries[c.nextIdx-1].UrlEntry
+}
+
+// addInvalid records an invalid entry skipped by the worker, it is reported
+// once the chunk is written. The skipped entry is the last one returned by
+// Next(), since a carried over entry is valid.
+func (c *urlsetChunk) addInvalid(err *EntryError) {
+	c.invalid = append(c.invalid, err)
+	c.entries[c.nextIdx-1].skipped = true
+}
+
+// GetUrlsetUrl implements Input, the URLs are resolved by the actual input.
+func (c *urlsetChunk) GetUrlsetUrl(int) string {
+	return ""
+}
+
+// nextFile returns a buffer for the next encoded file of the chunk.
+func (c *urlsetChunk) nextFile() *chunkFile {
+	if c.nfiles == len(c.files) {
+		c.files = append(c.files, &chunkFile{})
+	}
+
+	f := c.files[c.nfiles]
+	f.buf.Reset()
+	f.info = urlsetInfo{}
+	return f
+}
+
+// copyUrlEntry copies the given entry into dst, reusing the memory of dst.
+// The news of the entry, if any, is copied into news.
+func copyUrlEntry(dst *UrlEntry, news *News, src *UrlEntry) {
+	*dst = UrlEntry{
+		Loc:          src.Loc,
+		LastMod:      src.LastMod,
+		ChangeFreq:   src.ChangeFreq,
+		Priority:     src.Priority,
+		Alternates:   append(dst.Alternates[:0], src.Alternates...),
+		Images:       append(dst.Images[:0], src.Images...),
+		ImageDetails: append(dst.ImageDetails[:0], src.ImageDetails...),
+		Videos:       copyVideos(dst.Videos, src.Videos),
+		Extensions:   append(dst.Extensions[:0], src.Extensions...),
+	}
+	if src.News != nil {
+		*news = *src.News
+		dst.News = news
+	}
+}
+
+func copyVideos(dst, src []Video) []Video {
+	videos := dst[:0]
+	for i := range src {
+		var tags []string
+		if i < cap(dst) {
+			tags = dst[:cap(dst)][i].Tags[:0]
+		}
+
+		videos = append(videos, src[i])
+		videos[i].Tags = append(tags, src[i].Tags...)
+	}
+
+	return videos
+}
+
+// compressingOutput is implemented by outputs compressing urlset files. It
+// lets parallel writing compress the files in the workers.
+type compressingOutput interface {
+	// newCompressor returns a compressor used by a single worker.
+	newCompressor() compressor
+	// compressedUrlset is like Urlset() but the returned writer expects
+	// a compressed file.
+	compressedUrlset() io.Writer
+}
+
+type compressor interface {
+	compress(dst io.Writer, src []byte) error
+}
+
+// writeUrlsetsParallel writes urlset files like writeUrlsets, using the
+// given number of workers.
+//
+// The input is read by the calling goroutine in chunks of 50,000 entries,
+// which are encoded by the workers into one or more urlset files each. The
+// files are written to the output in the order of the chunks by a separate
+// goroutine, hence the output is used by a single goroutine at a time.
+func (s *sitemapWriter) writeUrlsetsParallel(
+	ctx context.Context,
+	o Output,
+	in Input,
+	workers int,
+) ([]urlsetInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Every worker may hold a chunk while the next one is read and the
+	// previous one is written, which bounds the memory usage.
+	free := make(chan *urlsetChunk, 2*workers+1)
+	for i := 0; i < cap(free); i++ {
+		free <- &urlsetChunk{}
+	}
+	jobs := make(chan *urlsetChunk)
+	results := make(chan *urlsetChunk, cap(free))
+
+	compressing, _ := o.(compressingOutput)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := sitemapWriter{cfg: s.cfg, host: s.host}
+			var c compressor
+			if compressing != nil {
+				c = compressing.newCompressor()
+			}
+			for chunk := range jobs {
+				w.encodeChunk(ctx, chunk, c)
+				results <- chunk
+			}
+		}()
+	}
+
+	var files []urlsetInfo
+	collected := make(chan error, 1)
+	go func() {
+		collected <- s.collectChunks(o, results, free, &files, cancel)
+	}()
+
+	readErr := s.readChunks(ctx, in, free, jobs)
+	close(jobs)
+	wg.Wait()
+	close(results)
+	if err := <-collected; err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Nothing was written, write a single empty file as writeUrlsets does.
+	if len(files) == 0 {
+		info, _, err := s.writeUrlset(ctx, o, in, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, info)
+	}
+
+	for i := range files {
+		s.resolveUrlsetInfo(in, o, i, &files[i])
+	}
+	return files, nil
+}
+
+// readChunks reads the input into chunks and passes them to the workers.
+// It returns the error reported by the input, in which case the last chunk
+// is dropped.
+func (s *sitemapWriter) readChunks(
+	ctx context.Context,
+	in Input,
+	free chan *urlsetChunk,
+	jobs chan<- *urlsetChunk,
+) error {
+	// A chunk fills whole urlset files, unless some of them are cut short
+	// by other limits.
+	chunkSize := maxSitemapCap / s.cfg.maxEntries() * s.cfg.maxEntries()
+	for seq := 0; ; seq++ {
+		var chunk *urlsetChunk
+		select {
+		case chunk = <-free:
+		case <-ctx.Done():
+			return nil
+		}
+
+		chunk.reset(seq)
+		for len(chunk.entries) < chunkSize {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			entry := in.Next()
+			if entry == nil {
+				if err := inputErr(in); err != nil {
+					return err
+				}
+				break
+			}
+			chunk.add(entry)
+		}
+		if len(chunk.entries) == 0 {
+			return nil
+		}
+
+		full := len(chunk.entries) == chunkSize
+		jobs <- chunk
+		if !full {
+			return nil
+		}
+	}
+}
+
+// encodeChunk writes the entries of the given chunk into urlset files, and
+// compresses the files if the compressor is set.
+func (s *sitemapWriter) encodeChunk(
+	ctx context.Context,
+	chunk *urlsetChunk,
+	c compressor,
+) {
+	s.onInvalid = chunk.addInvalid
+	var carryOverEntry *UrlEntry
+	for {
+		f := chunk.nextFile()
+		w := &f.buf
+		if c != nil {
+			s.fileBuf.Reset()
+			w = &s.fileBuf
+		}
+
+		info, co, err := s.writeUrlsetFile(ctx, w, chunk, carryOverEntry)
+		if err == nil && c != nil {
+			err = c.compress(&f.buf, s.fileBuf.Bytes())
+		}
+		if err != nil {
+			chunk.err = err
+			return
+		}
+		// All the entries of the chunk are skipped as invalid.
+		if info.entries == 0 {
+			return
+		}
+
+		f.info = info
+		chunk.nfiles++
+		carryOverEntry = co
+		if carryOverEntry == nil {
+			return
+		}
+	}
+}
+
+// collectChunks writes the files of encoded chunks to the output in the
+// order of the chunks, and returns the chunks to the free list. The writing
+// is canceled on the first error.
+func (s *sitemapWriter) collectChunks(
+	o Output,
+	results <-chan *urlsetChunk,
+	free chan<- *urlsetChunk,
+	files *[]urlsetInfo,
+	cancel context.CancelFunc,
+) error {
+	compressing, _ := o.(compressingOutput)
+	pending := map[int]*urlsetChunk{}
+	var firstErr error
+	nextSeq := 0
+	for chunk := range results {
+		pending[chunk.seq] = chunk
+		for {
+			chunk, ok := pending[nextSeq]
+			if !ok {
+				break
+			}
+			delete(pending, nextSeq)
+			nextSeq++
+
+			if firstErr == nil {
+				firstErr = s.writeChunk(o, compressing, chunk, files)
+				if firstErr != nil {
+					cancel()
+				}
+			}
+			free <- chunk
+		}
+	}
+
+	return firstErr
+}
+
+func (s *sitemapWriter) writeChunk(
+	o Output,
+	compressing compressingOutput,
+	chunk *urlsetChunk,
+	files *[]urlsetInfo,
+) error {
+	if chunk.err != nil {
+		return chunk.err
+	}
+
+	if s.onInvalid != nil {
+		for _, err := range chunk.invalid {
+			s.onInvalid(err)
+		}
+	}
+
+	for _, f := range chunk.files[:chunk.nfiles] {
+		var urlsetWriter io.Writer
+		if compressing != nil {
+			urlsetWriter = compressing.compressedUrlset()
+		} else {
+			urlsetWriter = o.Urlset()
+		}
+
+		idx := len(*files)
+		if _, err := urlsetWriter.Write(f.buf.Bytes()); err != nil {
+			return &WriteError{Kind: UrlsetFile, File: idx, Err: err}
+		}
+		if err := finalize(urlsetWriter); err != nil {
+			return &WriteError{Kind: UrlsetFile, File: idx, Entries: f.info.entries, Err: err}
+		}
+		*files = append(*files, f.info)
+	}
+
+	if s.alternates != nil {
+		for i := range chunk.entries {
+			if !chunk.entries[i].skipped {
+				s.alternates.add(&chunk.entries[i].UrlEntry)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWriter_Workers(t *testing.T) {
+	customEntry := func(idx int) *UrlEntry {
+		return &UrlEntry{
+			Loc:    fmt.Sprintf("http://goiguide.com/%d", idx),
+			Images: []string{fmt.Sprintf("http://goiguide.com/%d.jpg", idx)},
+		}
+	}
+	customUrl := func(idx int) string {
+		return fmt.Sprintf("urlset %03d", idx)
+	}
+
+	t.Run("sameAsSequential", func(t *testing.T) {
+		for _, size := range []int{0, 1, 50_000, 50_000*3 + 7} {
+			size := size
+			t.Run(strconv.Itoa(size), func(t *testing.T) {
+				RegisterTestingT(t)
+
+				in := dynamicInput{
+					Size:            size,
+					CustomEntry:     customEntry,
+					CustomUrlsetUrl: customUrl,
+				}
+				var exp bufferOuput
+				Ω(WriteAll(&exp, &in)).Should(BeNil())
+
+				in.Reset()
+				w := Writer{Workers: 3}
+				var out bufferOuput
+				Ω(w.WriteAll(&out, &in)).Should(BeNil())
+
+				Ω(out.index.String()).Should(Equal(exp.index.String()))
+				Ω(out.sitemaps).Should(HaveLen(len(exp.sitemaps)))
+				for i := range exp.sitemaps {
+					Ω(out.sitemaps[i].String()).Should(Equal(exp.sitemaps[i].String()))
+				}
+			})
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size:            50_000 + 30,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		w := Writer{MaxEntries: 7, Compact: true}
+		var exp bufferOuput
+		Ω(w.WriteAll(&exp, &in)).Should(BeNil())
+		Ω(exp.sitemaps).Should(HaveLen(7_148))
+
+		in.Reset()
+		w.Workers = 3
+		var out bufferOuput
+		Ω(w.WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.index.String()).Should(Equal(exp.index.String()))
+		Ω(out.sitemaps).Should(HaveLen(len(exp.sitemaps)))
+		for i := range exp.sitemaps {
+			Ω(out.sitemaps[i].String()).Should(Equal(exp.sitemaps[i].String()))
+		}
+	})
+
+	t.Run("extensions", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size: 50_000*2 + 1,
+			CustomEntry: func(idx int) *UrlEntry {
+				e := customEntry(idx)
+				if idx%1_000 == 0 {
+					e.Extensions = []Extension{geoExtension{Format: strconv.Itoa(idx)}}
+				}
+				return e
+			},
+			CustomUrlsetUrl: customUrl,
+		}
+		var exp bufferOuput
+		Ω(WriteAll(&exp, &in)).Should(BeNil())
+
+		in.Reset()
+		w := Writer{Workers: 2}
+		var out bufferOuput
+		Ω(w.WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(3))
+		for i := range exp.sitemaps {
+			Ω(out.sitemaps[i].String()).Should(Equal(exp.sitemaps[i].String()))
+		}
+		Ω(out.sitemaps[2].String()).Should(ContainSubstring("<geo:format>100000</geo:format>"))
+	})
+
+	t.Run("news", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		// The input returns the same entry every time.
+		in := dynamicInput{
+			Size: 50_000 + 1_500,
+			DefaultEntry: UrlEntry{
+				Loc: "http://goiguide.com/news",
+				News: &News{
+					Publication:     NewsPublication{Name: "Times", Language: "en"},
+					PublicationDate: time.Date(2008, 12, 23, 0, 0, 0, 0, time.UTC),
+					Title:           "Title",
+				},
+			},
+		}
+		var exp bufferOuput
+		Ω(WriteAll(&exp, &in)).Should(BeNil())
+		Ω(exp.sitemaps).Should(HaveLen(52))
+
+		in.Reset()
+		w := Writer{Workers: 4}
+		var out bufferOuput
+		Ω(w.WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.index.String()).Should(Equal(exp.index.String()))
+		Ω(out.sitemaps).Should(HaveLen(52))
+		Ω(out.sitemaps[51].String()).Should(Equal(exp.sitemaps[51].String()))
+	})
+
+	t.Run("reusedEntries", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		// Reader reuses the entry and its slices between calls to Next().
+		var doc bytes.Buffer
+		size := 50_000*2 + 3
+		doc.WriteString(string(urlsetHeaderOpen) + string(urlsetHeaderClose))
+		for i := 0; i < size; i++ {
+			fmt.Fprintf(&doc, "<url><loc>http://goiguide.com/%d</loc>", i)
+			for j := 0; j < i%3; j++ {
+				fmt.Fprintf(&doc, "<image:image><image:loc>%d-%d</image:loc>"+
+					"<image:title>%d</image:title></image:image>", i, j, j)
+			}
+			doc.WriteString("</url>\n")
+		}
+		doc.WriteString(string(urlsetFooter))
+
+		var exp bufferOuput
+		Ω(WriteAll(&exp, NewReader(bytes.NewReader(doc.Bytes()), customUrl))).
+			Should(BeNil())
+
+		w := Writer{Workers: 2}
+		var out bufferOuput
+		r := NewReader(bytes.NewReader(doc.Bytes()), customUrl)
+		Ω(w.WriteAll(&out, r)).Should(BeNil())
+		Ω(r.Err()).Should(BeNil())
+
+		Ω(out.sitemaps).Should(HaveLen(3))
+		for i := range exp.sitemaps {
+			Ω(out.sitemaps[i].String()).Should(Equal(exp.sitemaps[i].String()))
+		}
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size:            50_000 + 1,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		var exp bufferOuput
+		Ω(WriteAll(&exp, &in)).Should(BeNil())
+
+		in.Reset()
+		w := Writer{Workers: 2}
+		out := finalizingOutput{}
+		gzOut, err := NewGzipOutput(&out, gzip.BestSpeed)
+		Ω(err).Should(BeNil())
+		Ω(w.WriteAll(gzOut, &in)).Should(BeNil())
+
+		Ω(out.finalized).Should(Equal([]string{"urlset 0", "urlset 1", "index"}))
+		Ω(gunzip(&out.index)).Should(Equal(exp.index.String()))
+		Ω(out.sitemaps).Should(HaveLen(2))
+		for i := range exp.sitemaps {
+			Ω(gunzip(&out.sitemaps[i])).Should(Equal(exp.sitemaps[i].String()))
+		}
+	})
+
+	t.Run("invalidEntry", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size: 50_000*3 + 1,
+			CustomEntry: func(idx int) *UrlEntry {
+				e := customEntry(idx)
+				if idx == 50_000+10 {
+					e.ChangeFreq = "sometimes"
+				}
+				return e
+			},
+		}
+		w := Writer{Workers: 3}
+		var out bufferOuput
+		Ω(w.WriteAll(&out, &in)).Should(MatchError(
+			`entry "http://goiguide.com/50010" has invalid changefreq "sometimes"`))
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+
+	t.Run("failingOutput", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size:        50_000*4 + 1,
+			CustomEntry: customEntry,
+		}
+		w := Writer{Workers: 3}
+		out := finalizingOutput{FailUrlset: 2}
+		Ω(w.WriteAll(&out, &in)).Should(MatchError(
+			"sitemap: writing urlset file 1 failed after 50000 entries: finalize urlset 1"))
+		Ω(out.sitemaps).Should(HaveLen(2))
+		Ω(out.finalized).Should(Equal([]string{"urlset 0"}))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		in := dynamicInput{
+			Size: 50_000 * 4,
+			CustomEntry: func(idx int) *UrlEntry {
+				if idx == 50_000+5 {
+					cancel()
+				}
+				return customEntry(idx)
+			},
+		}
+		w := Writer{Workers: 2}
+		var out bufferOuput
+		Ω(w.WriteAllContext(ctx, &out, &in)).Should(MatchError(context.Canceled))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+
+	t.Run("checkAlternates", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		en := "http://goiguide.com/en/"
+		fr := "http://goiguide.com/fr/"
+		in := dynamicInput{
+			Size: 50_000*2 + 2,
+			CustomEntry: func(idx int) *UrlEntry {
+				switch idx {
+				case 0:
+					return &UrlEntry{Loc: en, Alternates: []Alternate{
+						{Hreflang: "fr", Href: fr},
+					}}
+				case 50_000*2 + 1:
+					return &UrlEntry{Loc: fr}
+				default:
+					return customEntry(idx)
+				}
+			},
+		}
+		w := Writer{Workers: 2, CheckAlternates: true}
+		var out bufferOuput
+		Ω(w.WriteAll(&out, &in)).Should(MatchError(`entry "http://goiguide.com/en/" ` +
+			`lists alternate "http://goiguide.com/fr/" which does not list it back`))
+		Ω(out.sitemaps).Should(HaveLen(3))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+}
+
+func TestCopyUrlEntry(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := UrlEntry{
+		Loc:          "http://goiguide.com/",
+		Alternates:   []Alternate{{Hreflang: "en", Href: "http://goiguide.com/"}},
+		Images:       []string{"1.jpg"},
+		ImageDetails: []Image{{Loc: "2.jpg", Title: "title"}},
+		News:         &News{Title: "news"},
+		Videos:       []Video{{Title: "video", Tags: []string{"a", "b"}}},
+	}
+	var dst UrlEntry
+	var news News
+	copyUrlEntry(&dst, &news, &src)
+	Ω(dst).Should(Equal(src))
+	Ω(dst.News).Should(BeIdenticalTo(&news))
+
+	src.Images[0] = "changed"
+	src.Videos[0].Tags[0] = "changed"
+	src.News.Title = "changed"
+	Ω(dst.Images[0]).Should(Equal("1.jpg"))
+	Ω(dst.Videos[0].Tags[0]).Should(Equal("a"))
+	Ω(dst.News.Title).Should(Equal("news"))
+
+	images := dst.Images
+	copyUrlEntry(&dst, &news, &UrlEntry{Loc: "other", Images: []string{"3.jpg"}})
+	Ω(dst).Should(Equal(UrlEntry{
+		Loc:          "other",
+		Alternates:   []Alternate{},
+		Images:       []string{"3.jpg"},
+		ImageDetails: []Image{},
+		Videos:       []Video{},
+	}))
+	Ω(&dst.Images[0]).Should(BeIdenticalTo(&images[0]))
+}
+
+func BenchmarkWriteAll_Workers(b *testing.B) {
+	var out discardOutput
+	in := dynamicInput{
+		Size: int(math.Pow10(6)),
+		DefaultEntry: UrlEntry{
+			Loc:     "http://www.example.com/qweqwe",
+			LastMod: minDate.AddDate(1, 2, 3),
+			Images:  []string{"http://www.example.com/qweqwe/thumb.jpg"},
+		},
+		CustomUrlsetUrl: func(int) string {
+			return "const url"
+		},
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		w := Writer{Workers: workers}
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				in.Reset()
+				_ = w.WriteAll(out, &in)
+			}
+		})
+	}
+}
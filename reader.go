@@ -0,0 +1,386 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DocumentKind is the kind of a sitemap document.
+type DocumentKind int
+
+const (
+	// DocumentUnknown means the root element has not been read yet.
+	DocumentUnknown DocumentKind = iota
+	// DocumentUrlset is a <urlset> document listing pages.
+	DocumentUrlset
+	// DocumentIndex is a <sitemapindex> document listing urlset files.
+	DocumentIndex
+)
+
+// Reader is a streaming parser of sitemap documents. It reads both urlset
+// and index documents, an entry is parsed from every <url> or <sitemap>
+// element respectively. For index documents, only Loc and LastMod are set.
+//
+// Reader implements Input, so parsed documents can be written back with
+// WriteAll, which fails with the parse error, if any. The returned entries,
+// including their slices, are reused by the following call to Next(), hence
+// the memory usage does not depend on the size of the document.
+//
+// Images with no metadata are parsed into UrlEntry.Images, the rest into
+// UrlEntry.ImageDetails. Unknown elements are skipped.
+type Reader struct {
+	dec          *xml.Decoder
+	getUrlsetUrl func(int) string
+
+	kind  DocumentKind
+	entry UrlEntry
+	news  News
+	err   error
+}
+
+// NewReader returns a Reader parsing the given document. The optional
+// getUrlsetUrl function is used to implement Input.GetUrlsetUrl().
+func NewReader(r io.Reader, getUrlsetUrl func(int) string) *Reader {
+	return &Reader{
+		dec:          xml.NewDecoder(r),
+		getUrlsetUrl: getUrlsetUrl,
+	}
+}
+
+// Kind returns the kind of the document, known once Next() is called.
+func (r *Reader) Kind() DocumentKind {
+	return r.kind
+}
+
+// Err returns the first error encountered while parsing the document.
+// It should be checked once Next() returns nil.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Next returns the next entry of the document, or nil once the document is
+// over or an error occurs, see Err().
+func (r *Reader) Next() *UrlEntry {
+	if r.err != nil {
+		return nil
+	}
+
+	for {
+		tok, err := r.dec.Token()
+		if err == io.EOF {
+			if r.kind == DocumentUnknown {
+				r.err = errors.New("sitemap: no urlset or sitemapindex element")
+			}
+			return nil
+		}
+		if err != nil {
+			r.err = err
+			return nil
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case r.kind == DocumentUnknown && start.Name.Local == "urlset":
+			r.kind = DocumentUrlset
+		case r.kind == DocumentUnknown && start.Name.Local == "sitemapindex":
+			r.kind = DocumentIndex
+		case r.kind == DocumentUnknown:
+			r.err = fmt.Errorf("sitemap: unexpected root element <%s>",
+				start.Name.Local)
+			return nil
+		case r.kind == DocumentUrlset && start.Name.Local == "url",
+			r.kind == DocumentIndex && start.Name.Local == "sitemap":
+			if err := r.readEntry(); err != nil {
+				r.err = err
+				return nil
+			}
+			return &r.entry
+		default:
+			if err := r.dec.Skip(); err != nil {
+				r.err = err
+				return nil
+			}
+		}
+	}
+}
+
+func (r *Reader) GetUrlsetUrl(idx int) string {
+	if r.getUrlsetUrl == nil {
+		return ""
+	}
+
+	return r.getUrlsetUrl(idx)
+}
+
+// readEntry parses the children of a <url> or <sitemap> element.
+func (r *Reader) readEntry() error {
+	e := &r.entry
+	*e = UrlEntry{
+		Alternates:   e.Alternates[:0],
+		Images:       e.Images[:0],
+		ImageDetails: e.ImageDetails[:0],
+		Videos:       e.Videos[:0],
+	}
+
+	return r.readChildren(func(start xml.StartElement) error {
+		var err error
+		switch start.Name.Space {
+		case "", xmlnsSitemapUri:
+			err = r.readCoreElement(start)
+		case xmlnsImageUri:
+			if start.Name.Local != "image" {
+				return r.dec.Skip()
+			}
+			err = r.readImage()
+		case xmlnsNewsUri:
+			if start.Name.Local != "news" {
+				return r.dec.Skip()
+			}
+			r.news = News{}
+			e.News = &r.news
+			err = r.readNews()
+		case xmlnsVideoUri:
+			if start.Name.Local != "video" {
+				return r.dec.Skip()
+			}
+			e.Videos = append(e.Videos, Video{})
+			err = r.readVideo(&e.Videos[len(e.Videos)-1])
+		case xmlnsXhtmlUri:
+			if start.Name.Local == "link" && attr(start, "rel") == "alternate" {
+				e.Alternates = append(e.Alternates, Alternate{
+					Hreflang: attr(start, "hreflang"),
+					Href:     attr(start, "href"),
+				})
+			}
+			err = r.dec.Skip()
+		default:
+			err = r.dec.Skip()
+		}
+		return err
+	})
+}
+
+func (r *Reader) readCoreElement(start xml.StartElement) error {
+	e := &r.entry
+	switch start.Name.Local {
+	case "loc":
+		return r.readText(&e.Loc)
+	case "lastmod":
+		return r.readTime(&e.LastMod)
+	case "changefreq":
+		var s string
+		err := r.readText(&s)
+		e.ChangeFreq = ChangeFreq(s)
+		return err
+	case "priority":
+		var s string
+		if err := r.readText(&s); err != nil {
+			return err
+		}
+		p, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("sitemap: invalid priority %q", s)
+		}
+		e.Priority = NewPriority(p)
+		return nil
+	default:
+		return r.dec.Skip()
+	}
+}
+
+func (r *Reader) readImage() error {
+	var img Image
+	err := r.readChildren(func(start xml.StartElement) error {
+		switch start.Name.Local {
+		case "loc":
+			return r.readText(&img.Loc)
+		case "caption":
+			return r.readText(&img.Caption)
+		case "title":
+			return r.readText(&img.Title)
+		case "geo_location":
+			return r.readText(&img.GeoLocation)
+		case "license":
+			return r.readText(&img.License)
+		default:
+			return r.dec.Skip()
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if img == (Image{Loc: img.Loc}) {
+		r.entry.Images = append(r.entry.Images, img.Loc)
+	} else {
+		r.entry.ImageDetails = append(r.entry.ImageDetails, img)
+	}
+	return nil
+}
+
+func (r *Reader) readNews() error {
+	n := &r.news
+	return r.readChildren(func(start xml.StartElement) error {
+		switch start.Name.Local {
+		case "publication":
+			return r.readChildren(func(start xml.StartElement) error {
+				switch start.Name.Local {
+				case "name":
+					return r.readText(&n.Publication.Name)
+				case "language":
+					return r.readText(&n.Publication.Language)
+				default:
+					return r.dec.Skip()
+				}
+			})
+		case "publication_date":
+			return r.readTime(&n.PublicationDate)
+		case "title":
+			return r.readText(&n.Title)
+		default:
+			return r.dec.Skip()
+		}
+	})
+}
+
+func (r *Reader) readVideo(v *Video) error {
+	return r.readChildren(func(start xml.StartElement) error {
+		var s string
+		switch start.Name.Local {
+		case "thumbnail_loc":
+			return r.readText(&v.ThumbnailLoc)
+		case "title":
+			return r.readText(&v.Title)
+		case "description":
+			return r.readText(&v.Description)
+		case "content_loc":
+			return r.readText(&v.ContentLoc)
+		case "player_loc":
+			return r.readText(&v.PlayerLoc)
+		case "duration":
+			if err := r.readText(&s); err != nil {
+				return err
+			}
+			secs, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("sitemap: invalid video duration %q", s)
+			}
+			v.Duration = time.Duration(secs) * time.Second
+			return nil
+		case "expiration_date":
+			return r.readTime(&v.ExpirationDate)
+		case "publication_date":
+			return r.readTime(&v.PublicationDate)
+		case "family_friendly":
+			err := r.readText(&s)
+			v.NotFamilyFriendly = s == "no"
+			return err
+		case "requires_subscription":
+			err := r.readText(&s)
+			v.RequiresSubscription = s == "yes"
+			return err
+		case "uploader":
+			return r.readText(&v.Uploader)
+		case "live":
+			err := r.readText(&s)
+			v.Live = s == "yes"
+			return err
+		case "tag":
+			err := r.readText(&s)
+			v.Tags = append(v.Tags, s)
+			return err
+		default:
+			return r.dec.Skip()
+		}
+	})
+}
+
+// readChildren calls the given function for every child element of the
+// current element, until the end of the current element is reached.
+// The function has to consume the child element entirely.
+func (r *Reader) readChildren(fn func(xml.StartElement) error) error {
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if err := fn(tok); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// readText reads the text content of the current element, surrounding
+// whitespace is trimmed.
+func (r *Reader) readText(dst *string) error {
+	var text []byte
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text = append(text, tok...)
+		case xml.StartElement:
+			if err := r.dec.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			*dst = strings.TrimSpace(string(text))
+			return nil
+		}
+	}
+}
+
+// readTime reads a date in the W3C Datetime format, which allows to omit the
+// time, or parts of it.
+func (r *Reader) readTime(dst *time.Time) error {
+	var s string
+	if err := r.readText(&s); err != nil {
+		return err
+	}
+
+	for _, layout := range w3cTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			*dst = t
+			return nil
+		}
+	}
+
+	return fmt.Errorf("sitemap: invalid date %q", s)
+}
+
+var w3cTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
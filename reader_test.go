@@ -0,0 +1,286 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestReader_Next(t *testing.T) {
+	t.Run("urlset", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		r := NewReader(strings.NewReader(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+	xmlns:image="http://www.google.com/schemas/sitemap-image/1.1"
+	xmlns:xhtml="http://www.w3.org/1999/xhtml">
+  <url>
+    <loc> http://goiguide.com/?a=1&amp;b=2 </loc>
+    <lastmod>2005-01-01</lastmod>
+    <changefreq>monthly</changefreq>
+    <priority>0.8</priority>
+    <xhtml:link rel="alternate" hreflang="fr" href="http://goiguide.com/fr/"/>
+    <image:image>
+      <image:loc>http://goiguide.com/1.jpg</image:loc>
+    </image:image>
+    <image:image>
+      <image:loc>http://goiguide.com/2.jpg</image:loc>
+      <image:caption>Kitchen</image:caption>
+      <image:title>Title</image:title>
+      <image:geo_location>Kitchener, ON</image:geo_location>
+      <image:license>http://goiguide.com/license</image:license>
+    </image:image>
+    <unknown><loc>ignored</loc></unknown>
+  </url>
+  <url>
+    <loc>http://goiguide.com/two</loc>
+    <lastmod>2004-12-23T18:00:15+00:00</lastmod>
+  </url>
+</urlset>
+		`), nil)
+
+		Ω(r.Kind()).Should(Equal(DocumentUnknown))
+		Ω(r.Next()).Should(Equal(&UrlEntry{
+			Loc:        "http://goiguide.com/?a=1&b=2",
+			LastMod:    time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC),
+			ChangeFreq: ChangeFreqMonthly,
+			Priority:   NewPriority(0.8),
+			Alternates: []Alternate{
+				{Hreflang: "fr", Href: "http://goiguide.com/fr/"},
+			},
+			Images: []string{"http://goiguide.com/1.jpg"},
+			ImageDetails: []Image{
+				{
+					Loc:         "http://goiguide.com/2.jpg",
+					Caption:     "Kitchen",
+					Title:       "Title",
+					GeoLocation: "Kitchener, ON",
+					License:     "http://goiguide.com/license",
+				},
+			},
+		}))
+		Ω(r.Kind()).Should(Equal(DocumentUrlset))
+
+		e := r.Next()
+		Ω(e.Loc).Should(Equal("http://goiguide.com/two"))
+		Ω(e.LastMod.Equal(time.Date(2004, 12, 23, 18, 0, 15, 0, time.UTC))).
+			Should(BeTrue())
+		Ω(e.Images).Should(BeEmpty())
+		Ω(e.ImageDetails).Should(BeEmpty())
+		Ω(e.Alternates).Should(BeEmpty())
+
+		Ω(r.Next()).Should(BeNil())
+		Ω(r.Next()).Should(BeNil())
+		Ω(r.Err()).Should(BeNil())
+	})
+
+	t.Run("index", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		r := NewReader(strings.NewReader(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>http://goiguide.com/sitemap-0.xml</loc>
+    <lastmod>2004-10-01T18:23:17+00:00</lastmod>
+  </sitemap>
+  <sitemap>
+    <loc>http://goiguide.com/sitemap-1.xml</loc>
+  </sitemap>
+</sitemapindex>
+		`), nil)
+
+		e := r.Next()
+		Ω(r.Kind()).Should(Equal(DocumentIndex))
+		Ω(e.Loc).Should(Equal("http://goiguide.com/sitemap-0.xml"))
+		Ω(e.LastMod.Equal(time.Date(2004, 10, 1, 18, 23, 17, 0, time.UTC))).
+			Should(BeTrue())
+
+		e = r.Next()
+		Ω(e.Loc).Should(Equal("http://goiguide.com/sitemap-1.xml"))
+		Ω(e.LastMod.IsZero()).Should(BeTrue())
+
+		Ω(r.Next()).Should(BeNil())
+		Ω(r.Err()).Should(BeNil())
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		testCases := []struct {
+			Name string
+			Doc  string
+			Err  string
+		}{
+			{
+				Name: "empty",
+				Doc:  ``,
+				Err:  "sitemap: no urlset or sitemapindex element",
+			},
+			{
+				Name: "unknownRoot",
+				Doc:  `<feed><url><loc>a</loc></url></feed>`,
+				Err:  "sitemap: unexpected root element <feed>",
+			},
+			{
+				Name: "truncated",
+				Doc:  `<urlset><url><loc>a</loc></url><url><loc>b`,
+				Err:  "XML syntax error on line 1: unexpected EOF",
+			},
+			{
+				Name: "invalidLastmod",
+				Doc:  `<urlset><url><loc>a</loc><lastmod>yesterday</lastmod></url></urlset>`,
+				Err:  `sitemap: invalid date "yesterday"`,
+			},
+			{
+				Name: "invalidPriority",
+				Doc:  `<urlset><url><loc>a</loc><priority>high</priority></url></urlset>`,
+				Err:  `sitemap: invalid priority "high"`,
+			},
+			{
+				Name: "malformed",
+				Doc:  `<urlset><url><loc>a</lo></url></urlset>`,
+				Err:  "XML syntax error on line 1: element <loc> closed by </lo>",
+			},
+		}
+
+		for _, tc := range testCases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				RegisterTestingT(t)
+
+				r := NewReader(strings.NewReader(tc.Doc), nil)
+				for r.Next() != nil {
+				}
+				Ω(r.Err()).Should(MatchError(tc.Err))
+				Ω(r.Next()).Should(BeNil())
+			})
+		}
+	})
+}
+
+func TestReader_GetUrlsetUrl(t *testing.T) {
+	RegisterTestingT(t)
+
+	r := NewReader(strings.NewReader(""), nil)
+	Ω(r.GetUrlsetUrl(3)).Should(Equal(""))
+
+	r = NewReader(strings.NewReader(""), func(idx int) string {
+		return fmt.Sprintf("@%d@", idx)
+	})
+	Ω(r.GetUrlsetUrl(3)).Should(Equal("@3@"))
+}
+
+func TestWriteAll_Reader(t *testing.T) {
+	t.Run("roundTrip", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{
+				Loc:        "http://goiguide.com/en/",
+				LastMod:    time.Date(2025, 11, 2, 11, 34, 58, 0, time.UTC),
+				ChangeFreq: ChangeFreqDaily,
+				Priority:   NewPriority(1),
+				Alternates: []Alternate{
+					{Hreflang: "en", Href: "http://goiguide.com/en/"},
+				},
+				Images: []string{"http://goiguide.com/1.jpg"},
+				ImageDetails: []Image{
+					{Loc: "http://goiguide.com/2.jpg", Caption: "<b>&"},
+				},
+				News: &News{
+					Publication:     NewsPublication{Name: "Times", Language: "en"},
+					PublicationDate: time.Date(2008, 12, 23, 0, 0, 0, 0, time.UTC),
+					Title:           "Title",
+				},
+				Videos: []Video{
+					{
+						ThumbnailLoc:         "thumb",
+						Title:                "title",
+						Description:          "description",
+						ContentLoc:           "content",
+						PlayerLoc:            "player",
+						Duration:             time.Minute,
+						ExpirationDate:       time.Date(2021, 11, 5, 19, 20, 30, 0, time.UTC),
+						PublicationDate:      time.Date(2007, 11, 5, 19, 20, 30, 0, time.UTC),
+						NotFamilyFriendly:    true,
+						RequiresSubscription: true,
+						Uploader:             "uploader",
+						Live:                 true,
+						Tags:                 []string{"a", "b"},
+					},
+				},
+			},
+			{
+				Loc: "http://goiguide.com/two",
+			},
+		}
+
+		var out bufferOuput
+		Ω(WriteAll(&out, &arrayInput{Arr: entries})).Should(BeNil())
+
+		var roundTrip bufferOuput
+		r := NewReader(bytes.NewReader(out.sitemaps[0].Bytes()), func(idx int) string {
+			return fmt.Sprintf("urlset no. %d", idx+1)
+		})
+		Ω(WriteAll(&roundTrip, r)).Should(BeNil())
+		Ω(r.Err()).Should(BeNil())
+
+		Ω(roundTrip.sitemaps).Should(HaveLen(1))
+		Ω(roundTrip.sitemaps[0].String()).Should(Equal(out.sitemaps[0].String()))
+		Ω(roundTrip.index.String()).Should(Equal(out.index.String()))
+	})
+
+	t.Run("multipleFiles", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		inputSize := 50_000 + 321
+		var buf bytes.Buffer
+		buf.WriteString(string(urlsetHeaderOpen) + string(urlsetHeaderClose))
+		for i := 0; i < inputSize; i++ {
+			fmt.Fprintf(&buf, "<url><loc>http://goiguide.com/%d</loc></url>\n", i)
+		}
+		buf.WriteString(string(urlsetFooter))
+
+		var out bufferOuput
+		r := NewReader(&buf, func(idx int) string {
+			return fmt.Sprintf("urlset %03d", idx)
+		})
+		Ω(WriteAll(&out, r)).Should(BeNil())
+		Ω(r.Err()).Should(BeNil())
+		assertOutput(&out, inputSize)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var out bufferOuput
+		r := NewReader(strings.NewReader(`<urlset><url><loc>a</loc></url><url><loc>b`), nil)
+		Ω(WriteAll(&out, r)).Should(MatchError("XML syntax error on line 1: unexpected EOF"))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+}
+
+func BenchmarkReader(b *testing.B) {
+	in := dynamicInput{
+		Size: 1_000,
+		DefaultEntry: UrlEntry{
+			Loc:     "http://www.example.com/qweqwe",
+			LastMod: minDate.AddDate(1, 2, 3),
+			Images:  []string{"http://www.example.com/qweqwe/thumb.jpg"},
+		},
+	}
+	var out bufferOuput
+	_ = WriteAll(&out, &in)
+	doc := out.sitemaps[0].Bytes()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r := NewReader(bytes.NewReader(doc), nil)
+		for r.Next() != nil {
+		}
+	}
+}
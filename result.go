@@ -0,0 +1,98 @@
+package sitemap
+
+import "time"
+
+// Result describes the files written by Writer.WriteAllResult or
+// Writer.WriteSections.
+type Result struct {
+	Urlsets []UrlsetResult
+	// Indexes describes the index files, a single one unless the urlset
+	// files do not fit into it. All of them should be submitted to search
+	// engines, e.g. listed in robots.txt.
+	Indexes []IndexResult
+}
+
+// UrlsetResult describes a written urlset file.
+type UrlsetResult struct {
+	// Index is the index of the file, as passed to GetUrlsetUrl().
+	Index int
+	// Section is the name of the section of the file, empty unless written
+	// by Writer.WriteSections.
+	Section string
+	// Url is the location of the file listed in the index file.
+	Url string
+	// Entries is the number of entries in the file.
+	Entries int
+	// Size is the uncompressed size of the file in bytes.
+	Size int
+	// MinLastMod and MaxLastMod are the earliest and the latest LastMod of
+	// the entries in the file, zero if no entry has a valid LastMod.
+	MinLastMod time.Time
+	MaxLastMod time.Time
+}
+
+// IndexResult describes a written index file.
+type IndexResult struct {
+	// Index is the index of the file, as passed to GetIndexUrl().
+	Index int
+	// Url is the location of the file, empty unless the input or the output
+	// implements IndexUrlProvider.
+	Url string
+	// Sitemaps is the number of urlset files listed in the file.
+	Sitemaps int
+	// Size is the uncompressed size of the file in bytes.
+	Size int
+}
+
+// Entries returns the total number of entries written.
+func (r *Result) Entries() int {
+	var n int
+	for i := range r.Urlsets {
+		n += r.Urlsets[i].Entries
+	}
+
+	return n
+}
+
+// IndexUrls returns the locations of the index files.
+func (r *Result) IndexUrls() []string {
+	urls := make([]string, len(r.Indexes))
+	for i := range r.Indexes {
+		urls[i] = r.Indexes[i].Url
+	}
+
+	return urls
+}
+
+func newResult(files []urlsetInfo, indexes []indexInfo) *Result {
+	r := &Result{
+		Urlsets: make([]UrlsetResult, len(files)),
+		Indexes: make([]IndexResult, len(indexes)),
+	}
+	for i, idx := range indexes {
+		r.Indexes[i] = IndexResult{
+			Index:    i,
+			Url:      idx.url,
+			Sitemaps: idx.sitemaps,
+			Size:     idx.size,
+		}
+	}
+	var idx int
+	for i, f := range files {
+		if i > 0 && f.section != files[i-1].section {
+			idx = 0
+		}
+		r.Urlsets[i] = UrlsetResult{
+			Index:      idx,
+			Section:    f.section,
+			Url:        f.url,
+			Entries:    f.entries,
+			Size:       f.size,
+			MinLastMod: f.minLastMod,
+			MaxLastMod: f.maxLastMod,
+		}
+		idx++
+	}
+
+	return r
+}
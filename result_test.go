@@ -0,0 +1,119 @@
+package sitemap
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWriter_WriteAllResult(t *testing.T) {
+	base := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	in := dynamicInput{
+		Size: 50_000 + 3,
+		CustomEntry: func(idx int) *UrlEntry {
+			e := &UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%d", idx)}
+			if idx%2 == 1 {
+				e.LastMod = base.Add(time.Duration(idx) * time.Minute)
+			}
+			return e
+		},
+		CustomUrlsetUrl: func(idx int) string {
+			return fmt.Sprintf("urlset %03d", idx)
+		},
+	}
+
+	for _, workers := range []int{0, 2} {
+		workers := workers
+		t.Run(fmt.Sprintf("workers%d", workers), func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in.Reset()
+			w := Writer{Workers: workers}
+			var out bufferOuput
+			r, err := w.WriteAllResult(context.Background(), &out, &in)
+			Ω(err).Should(BeNil())
+
+			Ω(r.Urlsets).Should(Equal([]UrlsetResult{
+				{
+					Index:      0,
+					Url:        "urlset 000",
+					Entries:    50_000,
+					Size:       out.sitemaps[0].Len(),
+					MinLastMod: base.Add(time.Minute),
+					MaxLastMod: base.Add(49_999 * time.Minute),
+				},
+				{
+					Index:      1,
+					Url:        "urlset 001",
+					Entries:    3,
+					Size:       out.sitemaps[1].Len(),
+					MinLastMod: base.Add(50_001 * time.Minute),
+					MaxLastMod: base.Add(50_001 * time.Minute),
+				},
+			}))
+			Ω(r.Indexes).Should(Equal([]IndexResult{
+				{Index: 0, Sitemaps: 2, Size: out.index.Len()},
+			}))
+			Ω(r.Entries()).Should(Equal(50_000 + 3))
+		})
+	}
+
+	t.Run("noLastMod", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var w Writer
+		var out bufferOuput
+		r, err := w.WriteAllResult(context.Background(), &out, &arrayInput{Arr: []UrlEntry{
+			{Loc: "http://goiguide.com/", LastMod: time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}})
+		Ω(err).Should(BeNil())
+		Ω(r.Urlsets).Should(HaveLen(1))
+		Ω(r.Urlsets[0].Url).Should(Equal("urlset no. 1"))
+		Ω(r.Urlsets[0].Entries).Should(Equal(1))
+		Ω(r.Urlsets[0].MinLastMod.IsZero()).Should(BeTrue())
+		Ω(r.Urlsets[0].MaxLastMod.IsZero()).Should(BeTrue())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var w Writer
+		var out bufferOuput
+		r, err := w.WriteAllResult(context.Background(), &out, &arrayInput{})
+		Ω(err).Should(BeNil())
+		Ω(r.Urlsets).Should(HaveLen(1))
+		Ω(r.Urlsets[0].Entries).Should(Equal(0))
+		Ω(r.Urlsets[0].Size).Should(Equal(out.sitemaps[0].Len()))
+		Ω(r.Entries()).Should(Equal(0))
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var w Writer
+		var out bufferOuput
+		gzOut, err := NewGzipOutput(&out, gzip.BestCompression)
+		Ω(err).Should(BeNil())
+		r, err := w.WriteAllResult(context.Background(), gzOut, &arrayInput{Arr: []UrlEntry{
+			{Loc: "http://goiguide.com/"},
+		}})
+		Ω(err).Should(BeNil())
+		Ω(r.Urlsets[0].Size).Should(Equal(len(gunzip(&out.sitemaps[0]))))
+		Ω(r.Indexes[0].Size).Should(Equal(len(gunzip(&out.index))))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var w Writer
+		r, err := w.WriteAllResult(context.Background(), &failiingOutput{FailIndex: true},
+			&arrayInput{Arr: []UrlEntry{{Loc: "http://goiguide.com/"}}})
+		Ω(err).Should(MatchError(
+			"sitemap: writing index file failed after 0 entries: failingWriter error"))
+		Ω(r).Should(BeNil())
+	})
+}
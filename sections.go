@@ -0,0 +1,158 @@
+package sitemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Section is a named family of urlset files, e.g. the listings or the blog
+// posts of a site, see Writer.WriteSections.
+type Section struct {
+	// Name identifies the section, e.g. "blog". It is passed to
+	// SectionOutput.Section() and reported by UrlsetResult.Section.
+	Name  string
+	Input Input
+}
+
+// WriteSections is like WriteAllResult but writes the entries of every
+// section into separate urlset files, listed by a single set of index files.
+//
+// The urlset files of a section are numbered from zero, independently of
+// the other sections: the input of the section is given the index of the
+// file within the section by GetUrlsetUrl(). If the output implements
+// SectionOutput, the urlset files of a section are written to the output it
+// returns for the section, which provides the URLs of the files if the
+// input does not. Otherwise, the files of all the sections are written to
+// o, which is given the index of a file among all of them. The index files
+// are always written to o.
+//
+// The sections are written one after another, in the given order. Their
+// names have to be non-empty and unique, and must not contain path
+// separators or "..", since outputs use them to name files. Every input is
+// stopped once the writing returns, see Stopper.
+func (w *Writer) WriteSections(
+	ctx context.Context,
+	o Output,
+	sections []Section,
+) (_ *Result, err error) {
+	defer func() {
+		for _, sec := range sections {
+			stopInput(sec.Input, err)
+		}
+	}()
+
+	if len(sections) == 0 {
+		return nil, errors.New("sitemap: no sections to write")
+	}
+	names := make(map[string]bool, len(sections))
+	for _, sec := range sections {
+		if err := checkSectionName(sec.Name); err != nil {
+			return nil, err
+		}
+		if names[sec.Name] {
+			return nil, fmt.Errorf("sitemap: duplicate section %q", sec.Name)
+		}
+		names[sec.Name] = true
+	}
+
+	return w.writeSections(ctx, o, sections)
+}
+
+// checkSectionName returns an error if the name of a section is empty or
+// could refer to a file outside of the directory of the output.
+func checkSectionName(name string) error {
+	if name == "" {
+		return errors.New("sitemap: section name is empty")
+	}
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("sitemap: invalid section name %q", name)
+	}
+
+	return nil
+}
+
+// writeSections writes the urlset files of the given sections followed by
+// the index files. A single unnamed section is written to o directly.
+func (w *Writer) writeSections(
+	ctx context.Context,
+	o Output,
+	sections []Section,
+) (*Result, error) {
+	if err := checkNamespaces(w.Namespaces); err != nil {
+		return nil, err
+	}
+
+	first := sections[0]
+	firstOutput, _ := sectionOutput(o, first.Name)
+	s := w.newSitemapWriter(first.Input, firstOutput)
+
+	var files []urlsetInfo
+	for _, sec := range sections {
+		out, shared := sectionOutput(o, sec.Name)
+		s.urlsetOffset = 0
+		if shared {
+			s.urlsetOffset = len(files)
+		}
+		sectionFiles, err := s.writeSection(ctx, out, sec.Input)
+		if err != nil {
+			var werr *WriteError
+			if errors.As(err, &werr) && werr.Kind == UrlsetFile {
+				werr.Section = sec.Name
+			}
+			return nil, err
+		}
+
+		for i := range sectionFiles {
+			sectionFiles[i].section = sec.Name
+		}
+		files = append(files, sectionFiles...)
+	}
+
+	// The index files are provided by the input only when there is a
+	// single one.
+	var in Input
+	if len(sections) == 1 {
+		in = first.Input
+	}
+	indexes, err := s.writeIndex(ctx, in, o, files)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResult(files, indexes), nil
+}
+
+// writeSection writes the urlset files of a single section.
+func (s *sitemapWriter) writeSection(
+	ctx context.Context,
+	o Output,
+	in Input,
+) ([]urlsetInfo, error) {
+	if s.cfg.Workers > 1 {
+		return s.writeUrlsetsParallel(ctx, o, in, s.cfg.Workers)
+	}
+
+	return s.writeUrlsets(ctx, o, in,
+		func(idx int, prevEntry *UrlEntry) (urlsetInfo, *UrlEntry, error) {
+			return s.writeUrlset(ctx, o, in, idx, prevEntry)
+		})
+}
+
+// sectionOutput returns the output of the urlset files of the named
+// section. It returns o itself for an unnamed section, or if o does not
+// provide a separate output for the section, and reports whether the files
+// of the section share o with the other sections.
+func sectionOutput(o Output, name string) (Output, bool) {
+	if name == "" {
+		return o, true
+	}
+	if p, ok := o.(SectionOutput); ok {
+		if out := p.Section(name); out != nil {
+			return out, false
+		}
+	}
+
+	return o, true
+}
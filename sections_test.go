@@ -0,0 +1,254 @@
+package sitemap
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWriter_WriteSections(t *testing.T) {
+	newSections := func() []Section {
+		sectionInput := func(name string, size int) Input {
+			return &dynamicInput{
+				Size: size,
+				CustomEntry: func(idx int) *UrlEntry {
+					return &UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%s/%d", name, idx)}
+				},
+				CustomUrlsetUrl: func(idx int) string {
+					return fmt.Sprintf("http://goiguide.com/sitemap-%s-%d.xml", name, idx)
+				},
+			}
+		}
+
+		return []Section{
+			{Name: "listings", Input: sectionInput("listings", 50_000+3)},
+			{Name: "blog", Input: sectionInput("blog", 2)},
+			{Name: "tours", Input: sectionInput("tours", 1)},
+		}
+	}
+
+	for _, workers := range []int{0, 2} {
+		workers := workers
+		t.Run(fmt.Sprintf("workers%d", workers), func(t *testing.T) {
+			RegisterTestingT(t)
+
+			w := Writer{Workers: workers}
+			out := sectionsOutput{}
+			r, err := w.WriteSections(context.Background(), &out, newSections())
+			Ω(err).Should(BeNil())
+
+			Ω(out.sections).Should(HaveLen(3))
+			Ω(out.sections["listings"].sitemaps).Should(HaveLen(2))
+			Ω(out.sections["blog"].sitemaps).Should(HaveLen(1))
+			Ω(out.sections["tours"].sitemaps).Should(HaveLen(1))
+			Ω(out.sitemaps).Should(BeEmpty())
+			Ω(out.sections["blog"].sitemaps[0].String()).
+				Should(ContainSubstring("<loc>http://goiguide.com/blog/1</loc>"))
+
+			Ω(out.index.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>http://goiguide.com/sitemap-listings-0.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>http://goiguide.com/sitemap-listings-1.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>http://goiguide.com/sitemap-blog-0.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>http://goiguide.com/sitemap-tours-0.xml</loc>
+  </sitemap>
+</sitemapindex>
+			`)))
+
+			type urlset struct {
+				Section string
+				Index   int
+				Entries int
+			}
+			var urlsets []urlset
+			for _, u := range r.Urlsets {
+				urlsets = append(urlsets, urlset{u.Section, u.Index, u.Entries})
+			}
+			Ω(urlsets).Should(Equal([]urlset{
+				{"listings", 0, 50_000},
+				{"listings", 1, 3},
+				{"blog", 0, 2},
+				{"tours", 0, 1},
+			}))
+			Ω(r.Indexes).Should(Equal([]IndexResult{
+				{Index: 0, Sitemaps: 4, Size: out.index.Len()},
+			}))
+		})
+	}
+
+	t.Run("noSectionOutput", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var w Writer
+		var out bufferOuput
+		r, err := w.WriteSections(context.Background(), &out, newSections())
+		Ω(err).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(4))
+		Ω(r.Urlsets[2].Url).Should(Equal("http://goiguide.com/sitemap-blog-0.xml"))
+	})
+
+	t.Run("sharedOutput", func(t *testing.T) {
+		newSections := func() []Section {
+			return []Section{
+				{Name: "listings", Input: &dynamicInput{Size: 50_000 + 3}},
+				{Name: "blog", Input: &dynamicInput{Size: 2}},
+				{Name: "tours", Input: &dynamicInput{Size: 1}},
+			}
+		}
+		expectedIndex := strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>http://goiguide.com/s-0.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>http://goiguide.com/s-1.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>http://goiguide.com/s-2.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>http://goiguide.com/s-3.xml</loc>
+  </sitemap>
+</sitemapindex>
+		`)
+
+		for _, workers := range []int{0, 2} {
+			workers := workers
+			t.Run(fmt.Sprintf("workers%d", workers), func(t *testing.T) {
+				RegisterTestingT(t)
+
+				w := Writer{Workers: workers}
+				var out urlsOutput
+				r, err := w.WriteSections(context.Background(), &out, newSections())
+				Ω(err).Should(BeNil())
+				Ω(out.sitemaps).Should(HaveLen(4))
+				Ω(out.index.String()).Should(Equal(expectedIndex))
+				Ω(r.Urlsets[2].Section).Should(Equal("blog"))
+				Ω(r.Urlsets[2].Index).Should(Equal(0))
+				Ω(r.Urlsets[2].Url).Should(Equal("http://goiguide.com/s-2.xml"))
+			})
+		}
+
+		t.Run("gzip", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			var out urlsOutput
+			gzOut, err := NewGzipOutput(&out, gzip.DefaultCompression)
+			Ω(err).Should(BeNil())
+			_, err = new(Writer).WriteSections(context.Background(), gzOut, newSections())
+			Ω(err).Should(BeNil())
+			Ω(out.sitemaps).Should(HaveLen(4))
+			Ω(gunzip(&out.index)).Should(Equal(expectedIndex))
+		})
+	})
+
+	t.Run("invalidSections", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var w Writer
+		in := &arrayInput{}
+		for _, tc := range []struct {
+			sections []Section
+			err      string
+		}{
+			{nil, "sitemap: no sections to write"},
+			{[]Section{{Input: in}}, "sitemap: section name is empty"},
+			{
+				[]Section{{Name: "blog", Input: in}, {Name: "blog", Input: in}},
+				`sitemap: duplicate section "blog"`,
+			},
+			{[]Section{{Name: "../x", Input: in}}, `sitemap: invalid section name "../x"`},
+			{[]Section{{Name: "a/b", Input: in}}, `sitemap: invalid section name "a/b"`},
+			{[]Section{{Name: `a\b`, Input: in}}, `sitemap: invalid section name "a\\b"`},
+			{[]Section{{Name: "..", Input: in}}, `sitemap: invalid section name ".."`},
+		} {
+			var out bufferOuput
+			_, err := w.WriteSections(context.Background(), &out, tc.sections)
+			Ω(err).Should(MatchError(tc.err))
+			Ω(out.sitemaps).Should(BeEmpty())
+		}
+	})
+
+	t.Run("writeError", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var w Writer
+		out := sectionsOutput{fail: "blog"}
+		_, err := w.WriteSections(context.Background(), &out, newSections())
+		Ω(err).Should(MatchError(`sitemap: writing urlset file 0 of section "blog" ` +
+			`failed after 0 entries: failingWriter error`))
+
+		var werr *WriteError
+		Ω(errors.As(err, &werr)).Should(BeTrue())
+		Ω(werr.Section).Should(Equal("blog"))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		listings := &cursorInput{Size: 3, FailAt: -1}
+		blog := &cursorInput{Size: 3, FailAt: 1}
+		tours := &cursorInput{Size: 3, FailAt: -1}
+
+		var w Writer
+		var out bufferOuput
+		_, err := w.WriteSections(context.Background(), &out, []Section{
+			{Name: "listings", Input: FromFallible(listings)},
+			{Name: "blog", Input: FromFallible(blog)},
+			{Name: "tours", Input: FromFallible(tours)},
+		})
+		Ω(err).Should(MatchError("cursor error at 1"))
+		Ω(listings.stopErr).Should(BeIdenticalTo(err))
+		Ω(blog.stopErr).Should(BeIdenticalTo(err))
+		Ω(tours.stopErr).Should(BeIdenticalTo(err))
+		Ω(tours.n).Should(Equal(0))
+	})
+}
+
+// urlsOutput is a bufferOuput providing the URLs of the urlset files.
+type urlsOutput struct {
+	bufferOuput
+}
+
+func (o *urlsOutput) GetUrlsetUrl(idx int) string {
+	return fmt.Sprintf("http://goiguide.com/s-%d.xml", idx)
+}
+
+// sectionsOutput writes the urlset files of every section into a separate
+// bufferOuput. The urlset files of the section named fail cannot be written.
+type sectionsOutput struct {
+	bufferOuput
+	fail string
+
+	sections map[string]*bufferOuput
+}
+
+func (o *sectionsOutput) Section(name string) Output {
+	if name == o.fail {
+		return &failiingOutput{FailUrlset: true}
+	}
+
+	if o.sections == nil {
+		o.sections = make(map[string]*bufferOuput)
+	}
+	if o.sections[name] == nil {
+		o.sections[name] = &bufferOuput{}
+	}
+
+	return o.sections[name]
+}
@@ -2,120 +2,1077 @@ package sitemap
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
 // WriteAll writes all files to the given output. Urlset files are written to
 // writers provided by o.Urlset(), the function will call it every time a new
 // file is to be written. The final index file is written to a writer provided
-// by o.Index().
-// The function aborts if any unexpected error occurs when writing.
+// by o.Index(). An index file lists at most 50,000 urlset files and is at most
+// 50MB, more index files are written if needed, each one to a new writer
+// provided by o.Index().
+// The function aborts if any unexpected error occurs when writing, a failure
+// of the output is returned as *WriteError. Invalid entries fail the writing
+// with errors matching ErrInvalidEntry, see also ErrLimitExceeded.
+//
+// WriteAll uses the default configuration, see Writer for the details.
 func WriteAll(o Output, in Input) error {
-	var s sitemapWriter
-	var nfiles int
+	var w Writer
+	return w.WriteAll(o, in)
+}
+
+// WriteAllContext is like WriteAll but stops writing once the context is
+// done. The context is checked before every entry and every file, in which
+// case the function returns ctx.Err(). Note that a call to in.Next() blocked
+// waiting for an entry is not interrupted.
+func WriteAllContext(ctx context.Context, o Output, in Input) error {
+	var w Writer
+	return w.WriteAllContext(ctx, o, in)
+}
+
+// Writer holds the configuration used to write sitemap files. The zero value
+// is ready to use and follows the limits defined by the sitemaps.org protocol.
+type Writer struct {
+	// MaxFileSize is the maximum size of an uncompressed urlset file in bytes.
+	// A new urlset file is started once the next entry would not fit into the
+	// current one. Zero means the protocol limit of 50MB (52,428,800 bytes).
+	MaxFileSize int
+	// CheckAlternates enables the check that alternate language versions of
+	// pages are reciprocal, i.e. every page listed as an alternate of another
+	// page is written too and lists that page back. The writing fails before
+	// the index file is written if the check fails.
+	// Note, the check keeps all alternate links in memory.
+	CheckAlternates bool
+	// Workers is the number of goroutines encoding urlset files in parallel.
+	// The input is read in chunks of up to 50,000 entries, a multiple of
+	// MaxEntries, every chunk is encoded into one or more files by a worker;
+	// the files are written to the output in order. Zero or one means the
+	// files are written sequentially. WriteIncremental ignores Workers and
+	// always writes the files sequentially.
+	// Note, every chunk ends its last file: when files are cut before
+	// MaxEntries, e.g. by MaxFileSize, the entries may be split into files
+	// differently than when written sequentially.
+	// Note, every worker holds up to 2 chunks of entries in memory.
+	Workers int
+	// ValidateUrls enables the validation of the URLs of every entry: the
+	// location, alternates and images have to be absolute http or https
+	// URLs of at most 2,048 characters, and the location has to be on the
+	// host of the first urlset file, if its URL is known. An invalid entry
+	// fails the writing with an *EntryError, unless SkipInvalid is set.
+	ValidateUrls bool
+	// SkipInvalid makes the writer skip entries with invalid URLs instead.
+	// Every skipped entry is reported to OnInvalid, if set, in the order of
+	// the input.
+	SkipInvalid bool
+	OnInvalid   func(err *EntryError)
+	// MaxEntries is the maximum number of entries of a urlset file. Zero, or
+	// a value above the protocol limit of 50,000, means 50,000. Files with
+	// news articles are limited to 1,000 entries regardless.
+	MaxEntries int
+	// MinLastMod is the earliest modification time written to the files;
+	// earlier LastMod values, including the zero time, are omitted. Zero
+	// means 2000-01-01.
+	MinLastMod time.Time
+	// Compact disables the indentation and the line breaks of the output.
+	Compact bool
+	// Namespaces lists extra XML namespaces declared by every urlset file,
+	// after the namespaces of the extensions used by the file. A namespace
+	// of a supported extension, e.g. "video", may be listed with its URI to
+	// declare it in every file. The prefixes have to be unique.
+	Namespaces []Namespace
+}
+
+// WriteAll writes all files to the given output, see WriteAll for the details.
+func (w *Writer) WriteAll(o Output, in Input) error {
+	return w.WriteAllContext(context.Background(), o, in)
+}
+
+// WriteAllContext writes all files to the given output, see WriteAllContext
+// for the details.
+func (w *Writer) WriteAllContext(ctx context.Context, o Output, in Input) error {
+	_, err := w.WriteAllResult(ctx, o, in)
+	return err
+}
+
+// WriteAllResult is like WriteAllContext but also returns a Result
+// describing the written files.
+func (w *Writer) WriteAllResult(ctx context.Context, o Output, in Input) (_ *Result, err error) {
+	defer func() { stopInput(in, err) }()
+
+	return w.writeSections(ctx, o, []Section{{Input: in}})
+}
+
+func (w *Writer) newSitemapWriter(in Input, o Output) *sitemapWriter {
+	s := &sitemapWriter{cfg: *w, onInvalid: w.OnInvalid}
+	if w.CheckAlternates {
+		s.alternates = newAlternatesChecker()
+	}
+	if w.ValidateUrls {
+		s.host = urlHost(s.resolveUrlsetUrl(in, o, 0))
+	}
+
+	return s
+}
+
+// writeUrlsets writes urlset files until the input is over. Every file is
+// written by the given function, given the index of the file, which returns
+// the entry to carry over to the next file, if any.
+func (s *sitemapWriter) writeUrlsets(
+	ctx context.Context,
+	o Output,
+	in Input,
+	writeFile func(idx int, prevEntry *UrlEntry) (urlsetInfo, *UrlEntry, error),
+) ([]urlsetInfo, error) {
+	var files []urlsetInfo
 	var carryOverEntry *UrlEntry
 	for {
-		nfiles++
-		var err error
-		carryOverEntry, err = s.writeUrlsetFile(o.Urlset(), in, carryOverEntry)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		info, co, err := writeFile(len(files), carryOverEntry)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
+		s.resolveUrlsetInfo(in, o, len(files), &info)
+		files = append(files, info)
+		carryOverEntry = co
 		if carryOverEntry == nil {
-			return s.writeIndexFile(o.Index(), in, nfiles)
+			return files, nil
 		}
 	}
 }
 
+// writeUrlset writes the urlset file at the given index to a writer provided
+// by the output.
+func (s *sitemapWriter) writeUrlset(
+	ctx context.Context,
+	o Output,
+	in Input,
+	idx int,
+	prevEntry *UrlEntry,
+) (urlsetInfo, *UrlEntry, error) {
+	urlsetWriter := o.Urlset()
+	info, co, err := s.writeUrlsetFile(ctx, urlsetWriter, in, prevEntry)
+	if err != nil {
+		if werr, ok := err.(*WriteError); ok {
+			werr.File = idx
+		}
+		return urlsetInfo{}, nil, err
+	}
+	if err := finalize(urlsetWriter); err != nil {
+		return urlsetInfo{}, nil, &WriteError{
+			Kind:    UrlsetFile,
+			File:    idx,
+			Entries: info.entries,
+			Err:     err,
+		}
+	}
+
+	return info, co, nil
+}
+
+// writeIndex writes the index files listing the given urlset files to
+// writers provided by the output.
+func (s *sitemapWriter) writeIndex(
+	ctx context.Context,
+	in Input,
+	o Output,
+	files []urlsetInfo,
+) ([]indexInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.alternates != nil {
+		if err := s.alternates.check(); err != nil {
+			return nil, err
+		}
+	}
+
+	parts := s.splitIndex(files, maxIndexCap, maxSitemapSize)
+	indexes := make([]indexInfo, 0, len(parts))
+	for i, part := range parts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		indexWriter := o.Index()
+		counter := countingWriter{underlying: indexWriter}
+		if err := s.writeIndexFile(&counter, part); err != nil {
+			if werr, ok := err.(*WriteError); ok {
+				werr.File = i
+			}
+			return nil, err
+		}
+		if err := finalize(indexWriter); err != nil {
+			return nil, &WriteError{Kind: IndexFile, File: i, Entries: len(part), Err: err}
+		}
+
+		indexes = append(indexes, indexInfo{
+			url:      s.resolveIndexUrl(in, o, i),
+			sitemaps: len(part),
+			size:     counter.n,
+		})
+	}
+
+	return indexes, nil
+}
+
+// splitIndex splits the given urlset files into parts listed by separate
+// index files, so that every index file lists at most maxCount files and is
+// at most maxSize bytes. There is always at least one part.
+func (s *sitemapWriter) splitIndex(
+	files []urlsetInfo,
+	maxCount, maxSize int,
+) [][]urlsetInfo {
+	var parts [][]urlsetInfo
+	start := 0
+	size := len(s.tag(tagIndexHeader)) + len(s.tag(tagIndexFooter))
+	for i := range files {
+		s.entryBuf.Reset()
+		s.writeXmlSitemap(&s.entryBuf, files[i].url, files[i].lastMod)
+		if i > start && (i-start >= maxCount || size+s.entryBuf.Len() > maxSize) {
+			parts = append(parts, files[start:i])
+			start = i
+			size = len(s.tag(tagIndexHeader)) + len(s.tag(tagIndexFooter))
+		}
+		size += s.entryBuf.Len()
+	}
+
+	return append(parts, files[start:])
+}
+
+// finalize notifies the writer the file is complete, if it is interested.
+func finalize(w io.Writer) error {
+	if f, ok := w.(Finalizer); ok {
+		return f.Finalize()
+	}
+
+	return nil
+}
+
+func (w *Writer) maxFileSize() int {
+	if w.MaxFileSize > 0 {
+		return w.MaxFileSize
+	}
+
+	return maxSitemapSize
+}
+
+func (w *Writer) maxEntries() int {
+	if w.MaxEntries > 0 && w.MaxEntries < maxSitemapCap {
+		return w.MaxEntries
+	}
+
+	return maxSitemapCap
+}
+
+func (w *Writer) minLastMod() time.Time {
+	if !w.MinLastMod.IsZero() {
+		return w.MinLastMod
+	}
+
+	return minDate
+}
+
 type sitemapWriter struct {
+	cfg Writer
+	// namespaces declared by the current urlset file
+	namespaces nsSet
+	// namespaces of extensions declared by the current urlset file, other
+	// than the ones above and the configured ones
+	extNamespaces []Namespace
+	// alternates collects alternate links of written entries, nil unless
+	// the check is enabled
+	alternates *alternatesChecker
+	// host is the host entries are expected on, empty if unknown
+	host string
+	// onInvalid is called for every skipped invalid entry
+	onInvalid func(err *EntryError)
+	// urlsetOffset is the number of urlset files written to the output by
+	// the previous sections, added to the index of a file given to the
+	// output, see sectionOutput
+	urlsetOffset int
+	// boundaries holds the locations of the last entries of the urlset files
+	// of the previous run, an entry at one of them ends the current file,
+	// see WriteIncremental
+	boundaries map[string]bool
 	// temporary buffer used to escape string values for XML
 	buf bytes.Buffer
+	// temporary buffer holding a single serialized entry, used to check the
+	// entry fits into the current file before writing it
+	entryBuf bytes.Buffer
+	// temporary buffer holding the first serialized entries of the current
+	// urlset file, written once the header is known, and their sizes
+	bodyBuf    bytes.Buffer
+	entrySizes []int
+	// temporary buffer holding a whole urlset file, used by incremental
+	// writing to compare the file with the previous one
+	fileBuf bytes.Buffer
+}
+
+// urlsetInfo describes a written urlset file.
+type urlsetInfo struct {
+	// url is the location of the file listed in the index file.
+	url string
+	// lastMod is the lastmod value of the file in the index file, the latest
+	// modification time of the entries in the file by default.
+	lastMod time.Time
+	// minLastMod and maxLastMod are the earliest and the latest modification
+	// times of the entries in the file.
+	minLastMod time.Time
+	maxLastMod time.Time
+	// size is the size of the file in bytes.
+	size int
+	// section is the name of the section the file belongs to, see
+	// Writer.WriteSections.
+	section string
+	// entries is the number of entries in the file, firstLoc and lastLoc
+	// are the locations of the first and the last ones.
+	entries  int
+	firstLoc string
+	lastLoc  string
+}
+
+type indexInfo struct {
+	// url is the location of the file, empty if unknown.
+	url string
+	// sitemaps is the number of urlset files listed in the file.
+	sitemaps int
+	// size is the size of the file in bytes.
+	size int
+}
+
+// resolveUrlsetInfo fills in the values of the urlset file at the given
+// index provided by the input, or by the output if the input does not.
+func (s *sitemapWriter) resolveUrlsetInfo(
+	in Input,
+	o Output,
+	idx int,
+	info *urlsetInfo,
+) {
+	info.url = s.resolveUrlsetUrl(in, o, idx)
+
+	if p, ok := in.(UrlsetLastModProvider); ok {
+		if t := p.GetUrlsetLastMod(idx); !t.IsZero() {
+			info.lastMod = t
+		}
+	}
 }
 
-// writeIndexFile writes Sitemap index file for N files.
-func (s *sitemapWriter) writeIndexFile(w io.Writer, in Input, nfiles int) error {
+// inputErr returns the error reported by the input, if it implements
+// ErrorReporter.
+func inputErr(in Input) error {
+	if r, ok := in.(ErrorReporter); ok {
+		return r.Err()
+	}
+
+	return nil
+}
+
+// stopInput notifies the input that the writing stopped, if it implements
+// Stopper.
+func stopInput(in Input, err error) {
+	if s, ok := in.(Stopper); ok {
+		s.Stop(err)
+	}
+}
+
+// resolveIndexUrl returns the URL of the index file at the given index
+// provided by the input, or by the output if the input does not, see
+// IndexUrlProvider.
+func (s *sitemapWriter) resolveIndexUrl(in Input, o Output, idx int) string {
+	if p, ok := in.(IndexUrlProvider); ok {
+		if url := p.GetIndexUrl(idx); url != "" {
+			return url
+		}
+	}
+	if p, ok := o.(IndexUrlProvider); ok {
+		return p.GetIndexUrl(idx)
+	}
+
+	return ""
+}
+
+// resolveUrlsetUrl returns the URL of the urlset file at the given index
+// provided by the input, or by the output if the input does not.
+func (s *sitemapWriter) resolveUrlsetUrl(in Input, o Output, idx int) string {
+	if url := in.GetUrlsetUrl(idx); url != "" {
+		return url
+	}
+	if p, ok := o.(UrlsetUrlProvider); ok {
+		return p.GetUrlsetUrl(s.urlsetOffset + idx)
+	}
+
+	return ""
+}
+
+// writeIndexFile writes Sitemap index file for the given urlset files.
+func (s *sitemapWriter) writeIndexFile(w io.Writer, files []urlsetInfo) error {
 	abortWriter := abortWriter{underlying: w}
 
-	_, _ = abortWriter.Write(indexHeader)
-	for i := 0; i < nfiles; i++ {
-		s.writeXmlSitemapLoc(&abortWriter, in.GetUrlsetUrl(i))
+	_, _ = abortWriter.Write(s.tag(tagIndexHeader))
+	var count int
+	for i := range files {
+		s.writeXmlSitemap(&abortWriter, files[i].url, files[i].lastMod)
+		if abortWriter.firstErr != nil {
+			break
+		}
+		count++
 	}
-	_, _ = abortWriter.Write(indexFooter)
+	_, _ = abortWriter.Write(s.tag(tagIndexFooter))
 
-	return abortWriter.firstErr
+	if abortWriter.firstErr != nil {
+		return &WriteError{Kind: IndexFile, Entries: count, Err: abortWriter.firstErr}
+	}
+	return nil
 }
 
 // writeUrlsetFile writes a single Sitemap Urlset file for the first 50K entries
-// in the given input.
+// in the given input, as long as they fit into the maximum file size.
 func (s *sitemapWriter) writeUrlsetFile(
+	ctx context.Context,
 	w io.Writer,
 	in Input,
 	prevEntry *UrlEntry,
-) (*UrlEntry, error) {
+) (urlsetInfo, *UrlEntry, error) {
 	abortWriter := abortWriter{underlying: w}
 
-	_, _ = abortWriter.Write(urlsetHeader)
-
-	var count int
 	// This is a continuation of a previous iteration. Write the carry-over
 	// entry without calling "Next()". Otherwise, we would lose an entry.
-	if prevEntry != nil {
-		s.writeXmlUrlEntry(&abortWriter, prevEntry)
-		count++
+	entry := prevEntry
+	if entry == nil {
+		entry = in.Next()
 	}
 
+	// The header declares the namespaces used by the entries of the file.
+	// The first entries are buffered until the header is written, see
+	// namespaceWindowSize; a following entry using a namespace not declared
+	// by the header starts a new file.
+	s.namespaces = s.configuredNamespaces()
+	s.extNamespaces = s.extNamespaces[:0]
+	s.bodyBuf.Reset()
+	// The last buffered entry exceeds the window.
+	s.bodyBuf.Grow(2 * namespaceWindowSize)
+	s.entrySizes = s.entrySizes[:0]
+	headerSize := s.writeUrlsetHeader(io.Discard)
+	var headerWritten bool
+	maxSize := s.cfg.maxFileSize() - len(s.tag(tagUrlsetFooter))
+
+	var info urlsetInfo
+	var size, count, written int
+	var hasNews bool
 	var carryOverEntry *UrlEntry
-	for ; ; count++ {
-		entry := in.Next()
-		if entry == nil {
+	for ; entry != nil; entry = in.Next() {
+		if err := ctx.Err(); err != nil {
+			return urlsetInfo{}, nil, err
+		}
+
+		if err := validateUrlEntry(entry); err != nil {
+			return urlsetInfo{}, nil, err
+		}
+		if err := s.checkExtensions(entry); err != nil {
+			return urlsetInfo{}, nil, err
+		}
+		if s.cfg.ValidateUrls {
+			if err := s.validateUrls(entry); err != nil {
+				if !s.cfg.SkipInvalid {
+					return urlsetInfo{}, nil, err
+				}
+				if s.onInvalid != nil {
+					s.onInvalid(err)
+				}
+				continue
+			}
+		}
+
+		maxCount := s.cfg.maxEntries()
+		if (hasNews || entry.News != nil) && maxCount > maxNewsSitemapCap {
+			maxCount = maxNewsSitemapCap
+		}
+		if count >= maxCount || (headerWritten && !s.declaresNamespaces(entry)) {
+			carryOverEntry = entry
 			break
 		}
 
-		if count >= maxSitemapCap {
+		s.entryBuf.Reset()
+		if err := s.writeXmlUrlEntry(&s.entryBuf, entry); err != nil {
+			return urlsetInfo{}, nil, err
+		}
+
+		// The namespaces used by a buffered entry might grow the header.
+		namespaces, nextExt := s.namespaces, len(s.extNamespaces)
+		entryHeaderSize := headerSize
+		if !headerWritten && s.declareNamespaces(entry) {
+			entryHeaderSize = s.writeUrlsetHeader(io.Discard)
+		}
+		if entryHeaderSize+size+s.entryBuf.Len() > maxSize {
+			if count == 0 {
+				return urlsetInfo{}, nil, limitExceededf("entry %q does not fit into a urlset "+
+					"file of %d bytes", entry.Loc, s.cfg.maxFileSize())
+			}
+
+			s.namespaces, s.extNamespaces = namespaces, s.extNamespaces[:nextExt]
 			carryOverEntry = entry
 			break
 		}
+		headerSize = entryHeaderSize
+
+		if headerWritten {
+			if _, err := abortWriter.Write(s.entryBuf.Bytes()); err != nil {
+				break
+			}
+			written++
+		} else {
+			s.bodyBuf.Write(s.entryBuf.Bytes())
+			s.entrySizes = append(s.entrySizes, s.entryBuf.Len())
+		}
+
+		size += s.entryBuf.Len()
+		if count == 0 {
+			info.firstLoc = entry.Loc
+		}
+		info.lastLoc = entry.Loc
+		count++
+		hasNews = hasNews || entry.News != nil
+		if s.alternates != nil {
+			s.alternates.add(entry)
+		}
+		if !entry.LastMod.Before(s.cfg.minLastMod()) {
+			if entry.LastMod.After(info.maxLastMod) {
+				info.maxLastMod = entry.LastMod
+			}
+			if info.minLastMod.IsZero() || entry.LastMod.Before(info.minLastMod) {
+				info.minLastMod = entry.LastMod
+			}
+		}
 
-		s.writeXmlUrlEntry(&abortWriter, entry)
+		if !headerWritten && s.bodyBuf.Len() >= namespaceWindowSize {
+			headerWritten = true
+			written = s.writeBufferedUrlset(&abortWriter)
+			if abortWriter.firstErr != nil {
+				break
+			}
+		}
+
+		if s.boundaries[entry.Loc] {
+			carryOverEntry = in.Next()
+			break
+		}
 	}
-	_, _ = abortWriter.Write(urlsetFooter)
+
+	// The input is over, a failed input must not produce a complete file.
+	if carryOverEntry == nil && abortWriter.firstErr == nil {
+		if err := inputErr(in); err != nil {
+			return urlsetInfo{}, nil, err
+		}
+	}
+	if !headerWritten {
+		written = s.writeBufferedUrlset(&abortWriter)
+	}
+	_, _ = abortWriter.Write(s.tag(tagUrlsetFooter))
 
 	if abortWriter.firstErr != nil {
-		return nil, abortWriter.firstErr
+		return urlsetInfo{}, nil, &WriteError{
+			Kind:    UrlsetFile,
+			Entries: written,
+			Err:     abortWriter.firstErr,
+		}
+	}
+
+	info.lastMod = info.maxLastMod
+	info.entries = count
+	info.size = headerSize + size + len(s.tag(tagUrlsetFooter))
+	return info, carryOverEntry, nil
+}
+
+// writeBufferedUrlset writes the header of the urlset file followed by the
+// buffered entries, and returns the number of entries written.
+func (s *sitemapWriter) writeBufferedUrlset(w io.Writer) int {
+	s.writeUrlsetHeader(w)
+
+	var written int
+	body := s.bodyBuf.Bytes()
+	for _, n := range s.entrySizes {
+		if _, err := w.Write(body[:n]); err != nil {
+			break
+		}
+		body = body[n:]
+		written++
 	}
 
-	return carryOverEntry, nil
+	return written
 }
 
-func (s *sitemapWriter) writeXmlUrlEntry(w io.Writer, e *UrlEntry) {
-	_, _ = w.Write(tagUrlOpen)
-	_, _ = w.Write(tagLocOpen)
+func (s *sitemapWriter) writeUrlsetHeader(w io.Writer) int {
+	size := len(s.tag(tagUrlsetHeaderOpen)) + len(s.tag(tagUrlsetHeaderClose))
+	_, _ = w.Write(s.tag(tagUrlsetHeaderOpen))
+	if s.namespaces.has(nsImage) {
+		size += len(xmlnsImage)
+		_, _ = w.Write(xmlnsImage)
+	}
+	if s.namespaces.has(nsNews) {
+		size += len(xmlnsNews)
+		_, _ = w.Write(xmlnsNews)
+	}
+	if s.namespaces.has(nsVideo) {
+		size += len(xmlnsVideo)
+		_, _ = w.Write(xmlnsVideo)
+	}
+	if s.namespaces.has(nsXhtml) {
+		size += len(xmlnsXhtml)
+		_, _ = w.Write(xmlnsXhtml)
+	}
+	for _, ns := range s.extNamespaces {
+		size += s.writeXmlns(w, ns)
+	}
+	for _, ns := range s.cfg.Namespaces {
+		// The namespaces of the supported extensions are declared above.
+		if _, ok := builtinNamespaces[ns.Prefix]; !ok {
+			size += s.writeXmlns(w, ns)
+		}
+	}
+	_, _ = w.Write(s.tag(tagUrlsetHeaderClose))
+	return size
+}
+
+// writeXmlns writes the declaration of the namespace, and returns its size.
+func (s *sitemapWriter) writeXmlns(w io.Writer, ns Namespace) int {
+	counter := countingWriter{underlying: w}
+	_, _ = counter.Write(xmlnsOpen)
+	s.writeXmlString(&counter, ns.Prefix)
+	_, _ = counter.Write(xmlnsValue)
+	s.writeXmlString(&counter, ns.URI)
+	_, _ = counter.Write(xmlnsClose)
+
+	return counter.n
+}
+
+// nsSet is a set of optional XML namespaces used by urlset files.
+type nsSet uint8
+
+const (
+	nsImage nsSet = 1 << iota
+	nsNews
+	nsVideo
+	nsXhtml
+)
+
+func (ns nsSet) has(other nsSet) bool {
+	return ns&other == other
+}
+
+// entryNamespaces returns the optional namespaces used by the entry.
+func entryNamespaces(e *UrlEntry) nsSet {
+	var ns nsSet
+	if len(e.Images)+len(e.ImageDetails) > 0 {
+		ns |= nsImage
+	}
+	if e.News != nil {
+		ns |= nsNews
+	}
+	if len(e.Videos) > 0 {
+		ns |= nsVideo
+	}
+	if len(e.Alternates) > 0 {
+		ns |= nsXhtml
+	}
+	return ns
+}
+
+// builtinNamespaces are the namespaces of the supported extensions, by
+// prefix.
+var builtinNamespaces = map[string]struct {
+	uri string
+	ns  nsSet
+}{
+	"image": {uri: xmlnsImageUri, ns: nsImage},
+	"news":  {uri: xmlnsNewsUri, ns: nsNews},
+	"video": {uri: xmlnsVideoUri, ns: nsVideo},
+	"xhtml": {uri: xmlnsXhtmlUri, ns: nsXhtml},
+}
+
+// configuredNamespaces returns the namespaces of the supported extensions
+// listed by Writer.Namespaces, declared by every urlset file.
+func (s *sitemapWriter) configuredNamespaces() nsSet {
+	var set nsSet
+	for _, ns := range s.cfg.Namespaces {
+		if b, ok := builtinNamespaces[ns.Prefix]; ok {
+			set |= b.ns
+		}
+	}
+
+	return set
+}
+
+// checkNamespaces returns an error if the namespaces listed by
+// Writer.Namespaces bind a prefix twice, or bind the prefix of a supported
+// extension to another URI.
+func checkNamespaces(namespaces []Namespace) error {
+	for i, ns := range namespaces {
+		if ns.Prefix == "" || ns.URI == "" {
+			return errors.New("sitemap: namespace without prefix or URI")
+		}
+		if b, ok := builtinNamespaces[ns.Prefix]; ok && b.uri != ns.URI {
+			return fmt.Errorf("sitemap: namespace prefix %q is bound to %q, not %q",
+				ns.Prefix, b.uri, ns.URI)
+		}
+		for _, prev := range namespaces[:i] {
+			if prev.Prefix == ns.Prefix {
+				return fmt.Errorf("sitemap: duplicate namespace prefix %q", ns.Prefix)
+			}
+		}
+	}
+
+	return nil
+}
+
+// declaresNamespaces reports whether all the namespaces used by the entry
+// are declared by the current urlset file.
+func (s *sitemapWriter) declaresNamespaces(e *UrlEntry) bool {
+	if !s.namespaces.has(entryNamespaces(e)) {
+		return false
+	}
+	for _, ext := range e.Extensions {
+		if !s.declaresNamespace(ext.Namespace()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// declareNamespaces adds the namespaces used by the entry to the ones
+// declared by the current urlset file. It reports whether any namespace was
+// added.
+func (s *sitemapWriter) declareNamespaces(e *UrlEntry) bool {
+	declared := s.namespaces
+	nextExt := len(s.extNamespaces)
+	s.namespaces |= entryNamespaces(e)
+	for _, ext := range e.Extensions {
+		ns := ext.Namespace()
+		if b, ok := builtinNamespaces[ns.Prefix]; ok && b.uri == ns.URI {
+			s.namespaces |= b.ns
+		} else if !s.declaresNamespace(ns) {
+			s.extNamespaces = append(s.extNamespaces, ns)
+		}
+	}
+
+	return s.namespaces != declared || len(s.extNamespaces) != nextExt
+}
+
+func (s *sitemapWriter) declaresNamespace(ns Namespace) bool {
+	if b, ok := builtinNamespaces[ns.Prefix]; ok && b.uri == ns.URI {
+		return s.namespaces.has(b.ns)
+	}
+	for _, declared := range s.cfg.Namespaces {
+		if declared == ns {
+			return true
+		}
+	}
+	for _, declared := range s.extNamespaces {
+		if declared == ns {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkExtensions checks the namespaces of the extensions of the entry do
+// not bind a prefix to a URI other than the one it is bound to already in
+// the current urlset file, or by another extension of the entry.
+func (s *sitemapWriter) checkExtensions(e *UrlEntry) error {
+	for i, ext := range e.Extensions {
+		ns := ext.Namespace()
+		if ns.Prefix == "" || ns.URI == "" {
+			return invalidEntryf("entry %q has an extension without namespace "+
+				"prefix or URI", e.Loc)
+		}
+		uri, ok := s.boundNamespace(ns.Prefix)
+		for j := 0; !ok && j < i; j++ {
+			if prev := e.Extensions[j].Namespace(); prev.Prefix == ns.Prefix {
+				uri, ok = prev.URI, true
+			}
+		}
+		if ok && uri != ns.URI {
+			return invalidEntryf("entry %q has an extension binding namespace "+
+				"prefix %q to %q, already bound to %q", e.Loc, ns.Prefix, ns.URI, uri)
+		}
+	}
+
+	return nil
+}
+
+// boundNamespace returns the URI the prefix is bound to by the supported
+// extensions, the configuration or the extensions of the current urlset
+// file.
+func (s *sitemapWriter) boundNamespace(prefix string) (string, bool) {
+	if b, ok := builtinNamespaces[prefix]; ok {
+		return b.uri, true
+	}
+	for _, ns := range s.cfg.Namespaces {
+		if ns.Prefix == prefix {
+			return ns.URI, true
+		}
+	}
+	for _, ns := range s.extNamespaces {
+		if ns.Prefix == prefix {
+			return ns.URI, true
+		}
+	}
+
+	return "", false
+}
+
+// validateUrlEntry checks the entry fields that cannot be written as is.
+func validateUrlEntry(e *UrlEntry) error {
+	if !e.ChangeFreq.IsValid() {
+		return invalidEntryf("entry %q has invalid changefreq %q",
+			e.Loc, e.ChangeFreq)
+	}
+	if !e.Priority.IsValid() {
+		return invalidEntryf("entry %q has invalid priority %v, "+
+			"expected a value between 0.0 and 1.0", e.Loc, e.Priority.value)
+	}
+	if len(e.Images)+len(e.ImageDetails) > maxImagesPerEntry {
+		return limitExceededf("entry %q has more than %d images",
+			e.Loc, maxImagesPerEntry)
+	}
+	for i := range e.ImageDetails {
+		if e.ImageDetails[i].Loc == "" {
+			return invalidEntryf("entry %q has an image without location", e.Loc)
+		}
+	}
+	for _, a := range e.Alternates {
+		if a.Hreflang == "" || a.Href == "" {
+			return invalidEntryf("entry %q has an alternate without hreflang "+
+				"or href", e.Loc)
+		}
+	}
+	if e.News != nil {
+		switch {
+		case e.News.Publication.Name == "":
+			return invalidEntryf("entry %q has no news publication name", e.Loc)
+		case e.News.Publication.Language == "":
+			return invalidEntryf("entry %q has no news publication language", e.Loc)
+		case e.News.PublicationDate.IsZero():
+			return invalidEntryf("entry %q has no news publication date", e.Loc)
+		case e.News.Title == "":
+			return invalidEntryf("entry %q has no news title", e.Loc)
+		}
+	}
+	for i := range e.Videos {
+		if err := validateVideo(&e.Videos[i]); err != nil {
+			return invalidEntryf("entry %q has invalid video %d: %w", e.Loc, i, err)
+		}
+	}
+
+	return nil
+}
+
+func validateVideo(v *Video) error {
+	switch {
+	case v.ThumbnailLoc == "":
+		return errors.New("no thumbnail location")
+	case v.Title == "":
+		return errors.New("no title")
+	case v.Description == "":
+		return errors.New("no description")
+	case utf8.RuneCountInString(v.Description) > maxVideoDescriptionLen:
+		return limitExceededf("description is longer than %d characters",
+			maxVideoDescriptionLen)
+	case v.ContentLoc == "" && v.PlayerLoc == "":
+		return errors.New("neither content nor player location")
+	case v.Duration != 0 &&
+		(v.Duration < time.Second || v.Duration > maxVideoDuration):
+		return limitExceededf("duration %s is out of range [1s, %s]",
+			v.Duration, maxVideoDuration)
+	case len(v.Tags) > maxVideoTags:
+		return limitExceededf("more than %d tags", maxVideoTags)
+	}
+
+	return nil
+}
+
+func (s *sitemapWriter) writeXmlUrlEntry(w io.Writer, e *UrlEntry) error {
+	_, _ = w.Write(s.tag(tagUrlOpen))
+	_, _ = w.Write(s.tag(tagLocOpen))
 	s.writeXmlString(w, e.Loc)
-	_, _ = w.Write(tagLocClose)
-	if !e.LastMod.Before(minDate) {
-		_, _ = w.Write(tagLastmodOpen)
+	_, _ = w.Write(s.tag(tagLocClose))
+	if !e.LastMod.Before(s.cfg.minLastMod()) {
+		_, _ = w.Write(s.tag(tagLastmodOpen))
 		s.writeXmlTime(w, e.LastMod)
-		_, _ = w.Write(tagLastmodClose)
+		_, _ = w.Write(s.tag(tagLastmodClose))
+	}
+	if e.ChangeFreq != "" {
+		_, _ = w.Write(s.tag(tagChangefreqOpen))
+		s.writeXmlString(w, string(e.ChangeFreq))
+		_, _ = w.Write(s.tag(tagChangefreqClose))
+	}
+	if e.Priority.set {
+		_, _ = w.Write(s.tag(tagPriorityOpen))
+		s.writeXmlPriority(w, e.Priority.value)
+		_, _ = w.Write(s.tag(tagPriorityClose))
+	}
+	for i := range e.Alternates {
+		_, _ = w.Write(s.tag(tagAlternateHreflang))
+		s.writeXmlString(w, e.Alternates[i].Hreflang)
+		_, _ = w.Write(s.tag(tagAlternateHref))
+		s.writeXmlString(w, e.Alternates[i].Href)
+		_, _ = w.Write(s.tag(tagAlternateClose))
 	}
 	if len(e.Images) > 0 {
 		for i := range e.Images {
-			_, _ = w.Write(tagImageOpen)
+			_, _ = w.Write(s.tag(tagImageOpen))
 			s.writeXmlString(w, e.Images[i])
-			_, _ = w.Write(tagImageClose)
+			_, _ = w.Write(s.tag(tagImageClose))
+		}
+	}
+	for i := range e.ImageDetails {
+		s.writeXmlImage(w, &e.ImageDetails[i])
+	}
+	if e.News != nil {
+		s.writeXmlNews(w, e.News)
+	}
+	for i := range e.Videos {
+		s.writeXmlVideo(w, &e.Videos[i])
+	}
+	for _, ext := range e.Extensions {
+		_, _ = w.Write(s.tag(tagExtensionOpen))
+		if err := ext.WriteXml(w); err != nil {
+			return invalidEntryf("entry %q has invalid extension %q: %w",
+				e.Loc, ext.Namespace().Prefix, err)
 		}
+		_, _ = w.Write(s.tag(tagExtensionClose))
+	}
+	_, _ = w.Write(s.tag(tagUrlClose))
+	return nil
+}
+
+func (s *sitemapWriter) writeXmlImage(w io.Writer, img *Image) {
+	_, _ = w.Write(s.tag(tagImageOpen))
+	s.writeXmlString(w, img.Loc)
+	_, _ = w.Write(s.tag(tagImageLocClose))
+	if img.Caption != "" {
+		s.writeXmlElement(w, s.tag(tagImageCaptionOpen), s.tag(tagImageCaptionClose),
+			img.Caption)
+	}
+	if img.Title != "" {
+		s.writeXmlElement(w, s.tag(tagImageTitleOpen), s.tag(tagImageTitleClose), img.Title)
+	}
+	if img.GeoLocation != "" {
+		s.writeXmlElement(w, s.tag(tagImageGeoLocationOpen), s.tag(tagImageGeoLocationClose),
+			img.GeoLocation)
+	}
+	if img.License != "" {
+		s.writeXmlElement(w, s.tag(tagImageLicenseOpen), s.tag(tagImageLicenseClose),
+			img.License)
+	}
+	_, _ = w.Write(s.tag(tagImageEnd))
+}
+
+func (s *sitemapWriter) writeXmlNews(w io.Writer, n *News) {
+	_, _ = w.Write(s.tag(tagNewsOpen))
+	_, _ = w.Write(s.tag(tagNewsNameOpen))
+	s.writeXmlString(w, n.Publication.Name)
+	_, _ = w.Write(s.tag(tagNewsNameClose))
+	_, _ = w.Write(s.tag(tagNewsLanguageOpen))
+	s.writeXmlString(w, n.Publication.Language)
+	_, _ = w.Write(s.tag(tagNewsLanguageClose))
+	_, _ = w.Write(s.tag(tagNewsPublicationDateOpen))
+	s.writeXmlTime(w, n.PublicationDate)
+	_, _ = w.Write(s.tag(tagNewsPublicationDateClose))
+	_, _ = w.Write(s.tag(tagNewsTitleOpen))
+	s.writeXmlString(w, n.Title)
+	_, _ = w.Write(s.tag(tagNewsTitleClose))
+	_, _ = w.Write(s.tag(tagNewsClose))
+}
+
+func (s *sitemapWriter) writeXmlVideo(w io.Writer, v *Video) {
+	_, _ = w.Write(s.tag(tagVideoOpen))
+	s.writeXmlElement(w, s.tag(tagVideoThumbnailLocOpen), s.tag(tagVideoThumbnailLocClose),
+		v.ThumbnailLoc)
+	s.writeXmlElement(w, s.tag(tagVideoTitleOpen), s.tag(tagVideoTitleClose), v.Title)
+	s.writeXmlElement(w, s.tag(tagVideoDescriptionOpen), s.tag(tagVideoDescriptionClose),
+		v.Description)
+	if v.ContentLoc != "" {
+		s.writeXmlElement(w, s.tag(tagVideoContentLocOpen), s.tag(tagVideoContentLocClose),
+			v.ContentLoc)
+	}
+	if v.PlayerLoc != "" {
+		s.writeXmlElement(w, s.tag(tagVideoPlayerLocOpen), s.tag(tagVideoPlayerLocClose),
+			v.PlayerLoc)
+	}
+	if v.Duration != 0 {
+		_, _ = w.Write(s.tag(tagVideoDurationOpen))
+		s.writeXmlInt(w, int64(v.Duration/time.Second))
+		_, _ = w.Write(s.tag(tagVideoDurationClose))
+	}
+	if !v.ExpirationDate.IsZero() {
+		_, _ = w.Write(s.tag(tagVideoExpirationDateOpen))
+		s.writeXmlTime(w, v.ExpirationDate)
+		_, _ = w.Write(s.tag(tagVideoExpirationDateClose))
+	}
+	if !v.PublicationDate.IsZero() {
+		_, _ = w.Write(s.tag(tagVideoPublicationDateOpen))
+		s.writeXmlTime(w, v.PublicationDate)
+		_, _ = w.Write(s.tag(tagVideoPublicationDateClose))
+	}
+	if v.NotFamilyFriendly {
+		_, _ = w.Write(s.tag(tagVideoNotFamilyFriendly))
+	}
+	if v.RequiresSubscription {
+		_, _ = w.Write(s.tag(tagVideoRequiresSubscription))
+	}
+	if v.Uploader != "" {
+		s.writeXmlElement(w, s.tag(tagVideoUploaderOpen), s.tag(tagVideoUploaderClose),
+			v.Uploader)
+	}
+	if v.Live {
+		_, _ = w.Write(s.tag(tagVideoLive))
+	}
+	for i := range v.Tags {
+		s.writeXmlElement(w, s.tag(tagVideoTagOpen), s.tag(tagVideoTagClose), v.Tags[i])
 	}
-	_, _ = w.Write(tagUrlClose)
+	_, _ = w.Write(s.tag(tagVideoClose))
 }
 
-func (s *sitemapWriter) writeXmlSitemapLoc(w io.Writer, loc string) {
-	_, _ = w.Write(tagSitemapOpen)
-	_, _ = w.Write(tagLocOpen)
+// writeXmlElement writes an element with the given escaped string value.
+func (s *sitemapWriter) writeXmlElement(
+	w io.Writer,
+	open, close []byte,
+	value string,
+) {
+	_, _ = w.Write(open)
+	s.writeXmlString(w, value)
+	_, _ = w.Write(close)
+}
+
+func (s *sitemapWriter) writeXmlSitemap(
+	w io.Writer,
+	loc string,
+	lastMod time.Time,
+) {
+	_, _ = w.Write(s.tag(tagSitemapOpen))
+	_, _ = w.Write(s.tag(tagLocOpen))
 	s.writeXmlString(w, loc)
-	_, _ = w.Write(tagLocClose)
-	_, _ = w.Write(tagSitemapClose)
+	_, _ = w.Write(s.tag(tagLocClose))
+	if !lastMod.Before(s.cfg.minLastMod()) {
+		_, _ = w.Write(s.tag(tagLastmodOpen))
+		s.writeXmlTime(w, lastMod)
+		_, _ = w.Write(s.tag(tagLastmodClose))
+	}
+	_, _ = w.Write(s.tag(tagSitemapClose))
 }
 
 func (s *sitemapWriter) writeXmlString(w io.Writer, str string) {
@@ -135,35 +1092,267 @@ func (s *sitemapWriter) writeXmlTime(w io.Writer, t time.Time) {
 	_, _ = w.Write(bs)
 }
 
-// Below are constant strings converted to byte slices ahead of time
+func (s *sitemapWriter) writeXmlInt(w io.Writer, n int64) {
+	// Same as above, format the value in a reusable buffer.
+	s.buf.Reset()
+	s.buf.Grow(32)
+	bs := strconv.AppendInt(s.buf.Bytes(), n, 10)
+	_, _ = w.Write(bs)
+}
+
+func (s *sitemapWriter) writeXmlPriority(w io.Writer, p float64) {
+	// Same as above, format the value in a reusable buffer. The protocol
+	// examples always have a fractional part, e.g. "1.0" rather than "1".
+	s.buf.Reset()
+	s.buf.Grow(32)
+	bs := strconv.AppendFloat(s.buf.Bytes(), p, 'f', -1, 64)
+	if bytes.IndexByte(bs, '.') < 0 {
+		bs = append(bs, '.', '0')
+	}
+	_, _ = w.Write(bs)
+}
+
+// xmlTag identifies a constant string written to sitemap files.
+type xmlTag int
+
+const (
+	tagIndexHeader xmlTag = iota
+	tagIndexFooter
+	tagUrlsetHeaderOpen
+	tagUrlsetHeaderClose
+	tagUrlsetFooter
+	tagSitemapOpen
+	tagSitemapClose
+	tagUrlOpen
+	tagUrlClose
+	tagLocOpen
+	tagLocClose
+	tagLastmodOpen
+	tagLastmodClose
+	tagChangefreqOpen
+	tagChangefreqClose
+	tagPriorityOpen
+	tagPriorityClose
+	tagAlternateHreflang
+	tagAlternateHref
+	tagAlternateClose
+	tagImageOpen
+	tagImageClose
+	tagImageLocClose
+	tagImageCaptionOpen
+	tagImageCaptionClose
+	tagImageTitleOpen
+	tagImageTitleClose
+	tagImageGeoLocationOpen
+	tagImageGeoLocationClose
+	tagImageLicenseOpen
+	tagImageLicenseClose
+	tagImageEnd
+	tagNewsOpen
+	tagNewsClose
+	tagNewsNameOpen
+	tagNewsNameClose
+	tagNewsLanguageOpen
+	tagNewsLanguageClose
+	tagNewsPublicationDateOpen
+	tagNewsPublicationDateClose
+	tagNewsTitleOpen
+	tagNewsTitleClose
+	tagVideoOpen
+	tagVideoClose
+	tagVideoThumbnailLocOpen
+	tagVideoThumbnailLocClose
+	tagVideoTitleOpen
+	tagVideoTitleClose
+	tagVideoDescriptionOpen
+	tagVideoDescriptionClose
+	tagVideoContentLocOpen
+	tagVideoContentLocClose
+	tagVideoPlayerLocOpen
+	tagVideoPlayerLocClose
+	tagVideoDurationOpen
+	tagVideoDurationClose
+	tagVideoExpirationDateOpen
+	tagVideoExpirationDateClose
+	tagVideoPublicationDateOpen
+	tagVideoPublicationDateClose
+	tagVideoNotFamilyFriendly
+	tagVideoRequiresSubscription
+	tagVideoUploaderOpen
+	tagVideoUploaderClose
+	tagVideoLive
+	tagVideoTagOpen
+	tagVideoTagClose
+	tagExtensionOpen
+	tagExtensionClose
+
+	numXmlTags
+)
+
+// xmlTags holds the constant strings converted to byte slices ahead of time
 // to avoid run-time allocations caused by string to byte slice conversions.
+type xmlTags [numXmlTags][]byte
+
+// Below are the strings of the pretty-printed output, the default.
 var (
 	indexHeader = []byte(xml.Header +
-		`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+		`<sitemapindex xmlns="` + xmlnsSitemapUri + `">` +
 		"\n",
 	)
 	indexFooter = []byte("</sitemapindex>")
 
-	urlsetHeader = []byte(xml.Header +
-		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">` +
-		"\n",
+	urlsetHeaderOpen = []byte(xml.Header +
+		`<urlset xmlns="` + xmlnsSitemapUri + `"`,
 	)
-	urlsetFooter = []byte(`</urlset>`)
-
-	tagSitemapOpen  = []byte("  <sitemap>\n")
-	tagSitemapClose = []byte("  </sitemap>\n")
-	tagUrlOpen      = []byte("  <url>\n")
-	tagUrlClose     = []byte("  </url>\n")
-	tagLocOpen      = []byte("    <loc>")
-	tagLocClose     = []byte("</loc>\n")
-	tagLastmodOpen  = []byte("    <lastmod>")
-	tagLastmodClose = []byte("</lastmod>\n")
-	tagImageOpen    = []byte("    <image:image>\n      <image:loc>")
-	tagImageClose   = []byte("</image:loc>\n    </image:image>\n")
+	urlsetHeaderClose = []byte(">\n")
+	urlsetFooter      = []byte(`</urlset>`)
+
+	xmlnsImage = []byte(` xmlns:image="` + xmlnsImageUri + `"`)
+	xmlnsNews  = []byte(` xmlns:news="` + xmlnsNewsUri + `"`)
+	xmlnsVideo = []byte(` xmlns:video="` + xmlnsVideoUri + `"`)
+	xmlnsXhtml = []byte(` xmlns:xhtml="` + xmlnsXhtmlUri + `"`)
+	xmlnsOpen  = []byte(` xmlns:`)
+	xmlnsValue = []byte(`="`)
+	xmlnsClose = []byte(`"`)
+
+	prettyTags = xmlTags{
+		tagIndexHeader:       indexHeader,
+		tagIndexFooter:       indexFooter,
+		tagUrlsetHeaderOpen:  urlsetHeaderOpen,
+		tagUrlsetHeaderClose: urlsetHeaderClose,
+		tagUrlsetFooter:      urlsetFooter,
+		tagSitemapOpen:       []byte("  <sitemap>\n"),
+		tagSitemapClose:      []byte("  </sitemap>\n"),
+		tagUrlOpen:           []byte("  <url>\n"),
+		tagUrlClose:          []byte("  </url>\n"),
+		tagLocOpen:           []byte("    <loc>"),
+		tagLocClose:          []byte("</loc>\n"),
+		tagLastmodOpen:       []byte("    <lastmod>"),
+		tagLastmodClose:      []byte("</lastmod>\n"),
+		tagChangefreqOpen:    []byte("    <changefreq>"),
+		tagChangefreqClose:   []byte("</changefreq>\n"),
+		tagPriorityOpen:      []byte("    <priority>"),
+		tagPriorityClose:     []byte("</priority>\n"),
+		tagAlternateHreflang: []byte(`    <xhtml:link rel="alternate" hreflang="`),
+		tagAlternateHref:     []byte(`" href="`),
+		tagAlternateClose:    []byte("\"/>\n"),
+
+		tagImageOpen:             []byte("    <image:image>\n      <image:loc>"),
+		tagImageClose:            []byte("</image:loc>\n    </image:image>\n"),
+		tagImageLocClose:         []byte("</image:loc>\n"),
+		tagImageCaptionOpen:      []byte("      <image:caption>"),
+		tagImageCaptionClose:     []byte("</image:caption>\n"),
+		tagImageTitleOpen:        []byte("      <image:title>"),
+		tagImageTitleClose:       []byte("</image:title>\n"),
+		tagImageGeoLocationOpen:  []byte("      <image:geo_location>"),
+		tagImageGeoLocationClose: []byte("</image:geo_location>\n"),
+		tagImageLicenseOpen:      []byte("      <image:license>"),
+		tagImageLicenseClose:     []byte("</image:license>\n"),
+		tagImageEnd:              []byte("    </image:image>\n"),
+
+		tagNewsOpen:                 []byte("    <news:news>\n      <news:publication>\n"),
+		tagNewsClose:                []byte("    </news:news>\n"),
+		tagNewsNameOpen:             []byte("        <news:name>"),
+		tagNewsNameClose:            []byte("</news:name>\n"),
+		tagNewsLanguageOpen:         []byte("        <news:language>"),
+		tagNewsLanguageClose:        []byte("</news:language>\n      </news:publication>\n"),
+		tagNewsPublicationDateOpen:  []byte("      <news:publication_date>"),
+		tagNewsPublicationDateClose: []byte("</news:publication_date>\n"),
+		tagNewsTitleOpen:            []byte("      <news:title>"),
+		tagNewsTitleClose:           []byte("</news:title>\n"),
+
+		tagVideoOpen:                 []byte("    <video:video>\n"),
+		tagVideoClose:                []byte("    </video:video>\n"),
+		tagVideoThumbnailLocOpen:     []byte("      <video:thumbnail_loc>"),
+		tagVideoThumbnailLocClose:    []byte("</video:thumbnail_loc>\n"),
+		tagVideoTitleOpen:            []byte("      <video:title>"),
+		tagVideoTitleClose:           []byte("</video:title>\n"),
+		tagVideoDescriptionOpen:      []byte("      <video:description>"),
+		tagVideoDescriptionClose:     []byte("</video:description>\n"),
+		tagVideoContentLocOpen:       []byte("      <video:content_loc>"),
+		tagVideoContentLocClose:      []byte("</video:content_loc>\n"),
+		tagVideoPlayerLocOpen:        []byte("      <video:player_loc>"),
+		tagVideoPlayerLocClose:       []byte("</video:player_loc>\n"),
+		tagVideoDurationOpen:         []byte("      <video:duration>"),
+		tagVideoDurationClose:        []byte("</video:duration>\n"),
+		tagVideoExpirationDateOpen:   []byte("      <video:expiration_date>"),
+		tagVideoExpirationDateClose:  []byte("</video:expiration_date>\n"),
+		tagVideoPublicationDateOpen:  []byte("      <video:publication_date>"),
+		tagVideoPublicationDateClose: []byte("</video:publication_date>\n"),
+		tagVideoNotFamilyFriendly:    []byte("      <video:family_friendly>no</video:family_friendly>\n"),
+		tagVideoRequiresSubscription: []byte("      <video:requires_subscription>yes</video:requires_subscription>\n"),
+		tagVideoUploaderOpen:         []byte("      <video:uploader>"),
+		tagVideoUploaderClose:        []byte("</video:uploader>\n"),
+		tagVideoLive:                 []byte("      <video:live>yes</video:live>\n"),
+		tagVideoTagOpen:              []byte("      <video:tag>"),
+		tagVideoTagClose:             []byte("</video:tag>\n"),
+
+		tagExtensionOpen:  []byte("    "),
+		tagExtensionClose: []byte("\n"),
+	}
+	// compactTags are prettyTags without the line breaks and indentation.
+	compactTags = prettyTags.compact()
+)
+
+// compact returns the tags without the line breaks and the indentation,
+// i.e. the spaces before elements or at the end of a tag.
+func (t xmlTags) compact() xmlTags {
+	for i, tag := range t {
+		res := make([]byte, 0, len(tag))
+		for j := 0; j < len(tag); j++ {
+			switch tag[j] {
+			case '\n':
+				continue
+			case ' ':
+				k := j
+				for k < len(tag) && tag[k] == ' ' {
+					k++
+				}
+				if k == len(tag) || tag[k] == '<' {
+					j = k - 1
+					continue
+				}
+			}
+			res = append(res, tag[j])
+		}
+		t[i] = res
+	}
+
+	return t
+}
+
+// tag returns the given constant string in the configured format.
+func (s *sitemapWriter) tag(t xmlTag) []byte {
+	if s.cfg.Compact {
+		return compactTags[t]
+	}
+
+	return prettyTags[t]
+}
+
+// Namespaces of the sitemap protocol and its extensions.
+const (
+	xmlnsSitemapUri = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	xmlnsImageUri   = "http://www.google.com/schemas/sitemap-image/1.1"
+	xmlnsNewsUri    = "http://www.google.com/schemas/sitemap-news/0.9"
+	xmlnsVideoUri   = "http://www.google.com/schemas/sitemap-video/1.1"
+	xmlnsXhtmlUri   = "http://www.w3.org/1999/xhtml"
 )
 
 var minDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
 
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	underlying io.Writer
+	n          int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.underlying.Write(p)
+	w.n += n
+	return n, err
+}
+
 type abortWriter struct {
 	underlying io.Writer
 	firstErr   error
@@ -181,6 +1370,21 @@ func (w *abortWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// namespaceWindowSize is the size of the first entries of a urlset file that
+// are buffered until its header is written, so the header declares the
+// namespaces used by all of them. An entry using another namespace later on
+// starts a new file.
+const namespaceWindowSize = 4 << 10
+
 const (
-	maxSitemapCap = 50_000
+	maxSitemapCap     = 50_000
+	maxSitemapSize    = 50 * 1024 * 1024
+	maxIndexCap       = 50_000
+	maxNewsSitemapCap = 1_000
+	maxImagesPerEntry = 1_000
+	maxUrlLen         = 2_048
+
+	maxVideoDescriptionLen = 2_048
+	maxVideoDuration       = 8 * time.Hour
+	maxVideoTags           = 32
 )
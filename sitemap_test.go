@@ -2,6 +2,7 @@ package sitemap
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -51,7 +52,7 @@ func TestWriteAll(t *testing.T) {
 		Ω(out.sitemaps).Should(HaveLen(1))
 		Ω(out.sitemaps[0].String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
 </urlset>
 		`)))
 	})
@@ -72,6 +73,7 @@ func TestWriteAll(t *testing.T) {
 <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
   <sitemap>
     <loc>urlset 000</loc>
+    <lastmod>2001-03-04T00:00:00Z</lastmod>
   </sitemap>
 </sitemapindex>
 		`)))
@@ -123,6 +125,7 @@ func TestWriteAll(t *testing.T) {
 <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
   <sitemap>
     <loc>urlset 000</loc>
+    <lastmod>2001-03-04T00:00:00Z</lastmod>
   </sitemap>
 </sitemapindex>
 		`)))
@@ -146,9 +149,11 @@ func TestWriteAll(t *testing.T) {
 <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
   <sitemap>
     <loc>urlset 000</loc>
+    <lastmod>2001-03-04T00:00:00Z</lastmod>
   </sitemap>
   <sitemap>
     <loc>urlset 001</loc>
+    <lastmod>2001-03-04T00:00:00Z</lastmod>
   </sitemap>
 </sitemapindex>
 		`)))
@@ -198,6 +203,184 @@ func TestWriteAll(t *testing.T) {
 		assertOutput(&out, in.Size)
 	})
 
+	t.Run("maxFileSize", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size:            1_000,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		w := Writer{MaxFileSize: 64 * 1024}
+		var out bufferOuput
+
+		Ω(w.WriteAll(&out, &in)).Should(BeNil())
+		Ω(len(out.sitemaps)).Should(BeNumerically(">", 1))
+
+		type urlList struct {
+			Locs []string `xml:"url>loc"`
+		}
+
+		var locs []string
+		for i := range out.sitemaps {
+			Ω(out.sitemaps[i].Len()).Should(BeNumerically("<=", w.MaxFileSize))
+
+			var s urlList
+			Ω(xml.Unmarshal(out.sitemaps[i].Bytes(), &s)).Should(BeNil())
+			locs = append(locs, s.Locs...)
+		}
+		Ω(locs).Should(HaveLen(in.Size))
+		for i := range locs {
+			Ω(locs[i]).Should(Equal(fmt.Sprintf("http://goiguide.com/%d", i)))
+		}
+	})
+
+	t.Run("news", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		newsEntry := func(idx int) *UrlEntry {
+			e := customEntry(idx)
+			e.News = &News{
+				Publication:     NewsPublication{Name: "Times", Language: "en"},
+				PublicationDate: time.Date(2008, 12, 23, 0, 0, 0, 0, time.UTC),
+				Title:           fmt.Sprintf("Title %d", idx),
+			}
+			return e
+		}
+
+		// 10 regular entries, 1,500 news articles, then 2,000 regular entries.
+		in := dynamicInput{
+			Size: 3_510,
+			CustomEntry: func(idx int) *UrlEntry {
+				if idx >= 10 && idx < 1_510 {
+					return newsEntry(idx)
+				}
+				return customEntry(idx)
+			},
+			CustomUrlsetUrl: customUrl,
+		}
+		var out bufferOuput
+
+		Ω(WriteAll(&out, &in)).Should(BeNil())
+
+		type urlList struct {
+			Locs []string `xml:"url>loc"`
+			News []string `xml:"url>news>title"`
+		}
+
+		expected := []struct {
+			Locs, News int
+		}{
+			{Locs: 1_000, News: 990},
+			{Locs: 1_000, News: 510},
+			{Locs: 1_510},
+		}
+		Ω(out.sitemaps).Should(HaveLen(len(expected)))
+		var locs []string
+		for i := range out.sitemaps {
+			var s urlList
+			Ω(xml.Unmarshal(out.sitemaps[i].Bytes(), &s)).Should(BeNil())
+			Ω(s.Locs).Should(HaveLen(expected[i].Locs), "urlset %d", i)
+			Ω(s.News).Should(HaveLen(expected[i].News), "urlset %d", i)
+			locs = append(locs, s.Locs...)
+
+			if expected[i].News > 0 {
+				Ω(out.sitemaps[i].String()).Should(ContainSubstring("xmlns:news"))
+			} else {
+				Ω(out.sitemaps[i].String()).ShouldNot(ContainSubstring("xmlns:news"))
+			}
+		}
+		Ω(locs).Should(HaveLen(in.Size))
+		for i := range locs {
+			Ω(locs[i]).Should(Equal(fmt.Sprintf("http://goiguide.com/%d", i)))
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		t.Run("canceledBeforeStart", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := dynamicInput{
+				Size:            3,
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			var out bufferOuput
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Ω(WriteAllContext(ctx, &out, &in)).Should(Equal(context.Canceled))
+			Ω(out.sitemaps).Should(BeEmpty())
+			Ω(out.index.Len()).Should(Equal(0))
+		})
+
+		t.Run("canceledWhileWriting", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			in := dynamicInput{
+				Size: 50_000*2 + 10,
+				CustomEntry: func(idx int) *UrlEntry {
+					if idx == 50_000+123 {
+						cancel()
+					}
+					return customEntry(idx)
+				},
+				CustomUrlsetUrl: customUrl,
+			}
+			var out bufferOuput
+
+			Ω(WriteAllContext(ctx, &out, &in)).Should(Equal(context.Canceled))
+			Ω(out.sitemaps).Should(HaveLen(2))
+			Ω(out.index.Len()).Should(Equal(0))
+			Ω(in.nextIdx).Should(Equal(50_000 + 124))
+		})
+
+		t.Run("deadline", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := NewChannelInput(customUrl)
+			var out bufferOuput
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			go func() {
+				for i := 0; ; i++ {
+					if err := in.FeedContext(ctx, customEntry(i)); err != nil {
+						in.Close()
+						return
+					}
+				}
+			}()
+
+			Ω(WriteAllContext(ctx, &out, in)).Should(Equal(context.DeadlineExceeded))
+			Ω(out.index.Len()).Should(Equal(0))
+		})
+	})
+
+	t.Run("finalize", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in := dynamicInput{
+			Size:            50_000*2 + 1,
+			CustomEntry:     customEntry,
+			CustomUrlsetUrl: customUrl,
+		}
+		var out finalizingOutput
+
+		Ω(WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.finalized).Should(Equal([]string{
+			"urlset 0", "urlset 1", "urlset 2", "index",
+		}))
+		for i := range out.sitemaps {
+			Ω(out.sitemaps[i].String()).Should(HaveSuffix("</urlset>"))
+		}
+		Ω(out.index.String()).Should(HaveSuffix("</sitemapindex>"))
+	})
+
 	t.Run("failures", func(t *testing.T) {
 		t.Run("urlset", func(t *testing.T) {
 			RegisterTestingT(t)
@@ -210,7 +393,8 @@ func TestWriteAll(t *testing.T) {
 				FailUrlset: true,
 			}
 
-			Ω(WriteAll(&out, &in)).Should(MatchError("failingWriter error"))
+			Ω(WriteAll(&out, &in)).Should(MatchError(
+				"sitemap: writing urlset file 0 failed after 0 entries: failingWriter error"))
 		})
 
 		t.Run("index", func(t *testing.T) {
@@ -224,7 +408,8 @@ func TestWriteAll(t *testing.T) {
 				FailIndex: true,
 			}
 
-			Ω(WriteAll(&out, &in)).Should(MatchError("failingWriter error"))
+			Ω(WriteAll(&out, &in)).Should(MatchError(
+				"sitemap: writing index file failed after 0 entries: failingWriter error"))
 		})
 
 		t.Run("both", func(t *testing.T) {
@@ -239,7 +424,89 @@ func TestWriteAll(t *testing.T) {
 				FailIndex:  true,
 			}
 
-			Ω(WriteAll(&out, &in)).Should(MatchError("failingWriter error"))
+			Ω(WriteAll(&out, &in)).Should(MatchError(
+				"sitemap: writing urlset file 0 failed after 0 entries: failingWriter error"))
+		})
+
+		t.Run("finalizeUrlset", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := dynamicInput{
+				Size:            50_000 + 1,
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			out := finalizingOutput{FailUrlset: 2}
+
+			Ω(WriteAll(&out, &in)).Should(MatchError(
+				"sitemap: writing urlset file 1 failed after 1 entries: finalize urlset 1"))
+			Ω(out.finalized).Should(Equal([]string{"urlset 0"}))
+			Ω(out.sitemaps).Should(HaveLen(2))
+			Ω(out.index.Len()).Should(Equal(0))
+		})
+
+		t.Run("finalizeIndex", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := dynamicInput{
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			out := finalizingOutput{FailIndex: true}
+
+			Ω(WriteAll(&out, &in)).Should(MatchError(
+				"sitemap: writing index file failed after 1 entries: finalize index"))
+			Ω(out.finalized).Should(Equal([]string{"urlset 0"}))
+		})
+
+		t.Run("notFinalizedOnFailure", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := dynamicInput{
+				Size:            3,
+				CustomEntry:     customEntry,
+				CustomUrlsetUrl: customUrl,
+			}
+			out := finalizingOutput{
+				Output: &failiingOutput{FailUrlset: true},
+			}
+
+			Ω(WriteAll(&out, &in)).Should(MatchError(
+				"sitemap: writing urlset file 0 failed after 0 entries: failingWriter error"))
+			Ω(out.finalized).Should(BeEmpty())
+		})
+
+		t.Run("namespaces", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			geo := Namespace{Prefix: "geo", URI: "http://www.google.com/geo/schemas/sitemap/1.0"}
+			for _, tc := range []struct {
+				namespaces []Namespace
+				err        string
+			}{
+				{[]Namespace{{Prefix: "geo"}}, "sitemap: namespace without prefix or URI"},
+				{
+					[]Namespace{geo, {Prefix: "video", URI: "http://example.com/"}},
+					`sitemap: namespace prefix "video" is bound to "` + xmlnsVideoUri +
+						`", not "http://example.com/"`,
+				},
+				{
+					[]Namespace{geo, {Prefix: "geo", URI: "http://example.com/"}},
+					`sitemap: duplicate namespace prefix "geo"`,
+				},
+				{[]Namespace{geo, geo}, `sitemap: duplicate namespace prefix "geo"`},
+			} {
+				w := Writer{Namespaces: tc.namespaces}
+				var out bufferOuput
+				Ω(w.WriteAll(&out, &arrayInput{Arr: []UrlEntry{{Loc: "a"}}})).
+					Should(MatchError(tc.err))
+				Ω(out.sitemaps).Should(BeEmpty())
+
+				_, err := w.WriteIncremental(context.Background(), &out,
+					&arrayInput{Arr: []UrlEntry{{Loc: "a"}}}, nil)
+				Ω(err).Should(MatchError(tc.err))
+				Ω(out.sitemaps).Should(BeEmpty())
+			}
 		})
 	})
 }
@@ -251,23 +518,23 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 		var s sitemapWriter
 		var out bytes.Buffer
 		var in arrayInput
-		co, err := s.writeUrlsetFile(&out, &in, nil)
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
 		Ω(err).Should(BeNil())
 		Ω(co).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
 </urlset>
 		`)))
 
 		out.Reset()
 		in = arrayInput{Arr: []UrlEntry{{}, {}, {}, {}}}
-		co, err = s.writeUrlsetFile(&out, &in, nil)
+		_, co, err = s.writeUrlsetFile(context.Background(), &out, &in, nil)
 		Ω(err).Should(BeNil())
 		Ω(co).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
   <url>
     <loc></loc>
   </url>
@@ -305,12 +572,13 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 		var s sitemapWriter
 		var out bytes.Buffer
 		in := arrayInput{Arr: entries}
-		co, err := s.writeUrlsetFile(&out, &in, nil)
+		info, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
 		Ω(err).Should(BeNil())
 		Ω(co).Should(BeNil())
+		Ω(info.lastMod).Should(Equal(time.Date(2015, 7, 22, 15, 48, 2, 0, time.UTC)))
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
   <url>
     <loc>one</loc>
   </url>
@@ -339,12 +607,12 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 		var s sitemapWriter
 		var out bytes.Buffer
 		in := arrayInput{Arr: entries}
-		co, err := s.writeUrlsetFile(&out, &in, nil)
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
 		Ω(err).Should(BeNil())
 		Ω(co).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
   <url>
     <loc></loc>
   </url>
@@ -370,7 +638,7 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 
 		out.Reset()
 		in = arrayInput{Arr: entries}
-		co, err = s.writeUrlsetFile(&out, &in, nil)
+		_, co, err = s.writeUrlsetFile(context.Background(), &out, &in, nil)
 		Ω(err).Should(BeNil())
 		Ω(co).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
@@ -410,6 +678,443 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 		`)))
 	})
 
+	t.Run("imageDetails", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{
+				Loc:    "one",
+				Images: []string{"a"},
+				ImageDetails: []Image{
+					{
+						Loc:         "b",
+						Caption:     "Living room & kitchen",
+						Title:       "Living room",
+						GeoLocation: "Kitchener, ON",
+						License:     "http://goiguide.com/license",
+					},
+					{
+						Loc:   "c",
+						Title: "Bedroom",
+					},
+				},
+			},
+		}
+
+		var s sitemapWriter
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url>
+    <loc>one</loc>
+    <image:image>
+      <image:loc>a</image:loc>
+    </image:image>
+    <image:image>
+      <image:loc>b</image:loc>
+      <image:caption>Living room &amp; kitchen</image:caption>
+      <image:title>Living room</image:title>
+      <image:geo_location>Kitchener, ON</image:geo_location>
+      <image:license>http://goiguide.com/license</image:license>
+    </image:image>
+    <image:image>
+      <image:loc>c</image:loc>
+      <image:title>Bedroom</image:title>
+    </image:image>
+  </url>
+</urlset>
+		`)))
+	})
+
+	t.Run("changefreqPriority", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{
+				Loc:        "one",
+				LastMod:    time.Date(2015, 7, 22, 15, 48, 2, 0, time.UTC),
+				ChangeFreq: ChangeFreqDaily,
+				Priority:   NewPriority(0.8),
+				Images:     []string{"a"},
+			},
+			{
+				Loc:      "two",
+				Priority: NewPriority(0),
+			},
+			{
+				Loc:        "three",
+				ChangeFreq: ChangeFreqNever,
+			},
+		}
+
+		var s sitemapWriter
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url>
+    <loc>one</loc>
+    <lastmod>2015-07-22T15:48:02Z</lastmod>
+    <changefreq>daily</changefreq>
+    <priority>0.8</priority>
+    <image:image>
+      <image:loc>a</image:loc>
+    </image:image>
+  </url>
+  <url>
+    <loc>two</loc>
+    <priority>0.0</priority>
+  </url>
+  <url>
+    <loc>three</loc>
+    <changefreq>never</changefreq>
+  </url>
+</urlset>
+		`)))
+	})
+
+	t.Run("alternates", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{
+				Loc: "http://goiguide.com/en/",
+				Alternates: []Alternate{
+					{Hreflang: "en", Href: "http://goiguide.com/en/"},
+					{Hreflang: "fr", Href: "http://goiguide.com/fr/?a=1&b=2"},
+				},
+				Images: []string{"a"},
+			},
+			{
+				Loc: "two",
+			},
+		}
+
+		var s sitemapWriter
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1" xmlns:xhtml="http://www.w3.org/1999/xhtml">
+  <url>
+    <loc>http://goiguide.com/en/</loc>
+    <xhtml:link rel="alternate" hreflang="en" href="http://goiguide.com/en/"/>
+    <xhtml:link rel="alternate" hreflang="fr" href="http://goiguide.com/fr/?a=1&amp;b=2"/>
+    <image:image>
+      <image:loc>a</image:loc>
+    </image:image>
+  </url>
+  <url>
+    <loc>two</loc>
+  </url>
+</urlset>
+		`)))
+	})
+
+	t.Run("news", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{
+				Loc: "one",
+				News: &News{
+					Publication: NewsPublication{
+						Name:     "The <Example> Times",
+						Language: "en",
+					},
+					PublicationDate: time.Date(2008, 12, 23, 0, 0, 0, 0, time.UTC),
+					Title:           "Companies A & B in Merger Talks",
+				},
+			},
+			{
+				Loc: "two",
+			},
+		}
+
+		var s sitemapWriter
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:news="http://www.google.com/schemas/sitemap-news/0.9">
+  <url>
+    <loc>one</loc>
+    <news:news>
+      <news:publication>
+        <news:name>The &lt;Example&gt; Times</news:name>
+        <news:language>en</news:language>
+      </news:publication>
+      <news:publication_date>2008-12-23T00:00:00Z</news:publication_date>
+      <news:title>Companies A &amp; B in Merger Talks</news:title>
+    </news:news>
+  </url>
+  <url>
+    <loc>two</loc>
+  </url>
+</urlset>
+		`)))
+	})
+
+	t.Run("newsPerFile", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		news := News{
+			Publication:     NewsPublication{Name: "Times", Language: "en"},
+			PublicationDate: time.Date(2008, 12, 23, 0, 0, 0, 0, time.UTC),
+			Title:           "Title",
+		}
+		entries := []UrlEntry{
+			{Loc: "one"},
+			{Loc: "two", News: &news},
+			{Loc: "three"},
+		}
+
+		// The namespace is declared by the file of the news article only.
+		s := sitemapWriter{cfg: Writer{MaxEntries: 2, Compact: true}}
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(Equal(&entries[2]))
+		Ω(out.String()).Should(ContainSubstring(`xmlns:news=`))
+		Ω(out.String()).Should(ContainSubstring(`<url><loc>one</loc></url>`))
+
+		out.Reset()
+		_, co, err = s.writeUrlsetFile(context.Background(), &out, &in, co)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(out.String()).ShouldNot(ContainSubstring(`xmlns:news=`))
+	})
+
+	t.Run("videos", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{
+				Loc: "one",
+				Videos: []Video{
+					{
+						ThumbnailLoc:         "http://www.example.com/thumbs/123.jpg",
+						Title:                "Grilling steaks for summer",
+						Description:          "Alkis shows you how to get perfectly done steaks every time",
+						ContentLoc:           "http://streamserver.example.com/video123.mp4",
+						PlayerLoc:            "http://www.example.com/videoplayer.php?video=123&autoplay=1",
+						Duration:             10*time.Minute + 900*time.Millisecond,
+						ExpirationDate:       time.Date(2021, 11, 5, 19, 20, 30, 0, time.UTC),
+						PublicationDate:      time.Date(2007, 11, 5, 19, 20, 30, 0, time.UTC),
+						NotFamilyFriendly:    true,
+						RequiresSubscription: true,
+						Uploader:             "GrillyMcGrillserson",
+						Live:                 true,
+						Tags:                 []string{"steak", "meat"},
+					},
+					{
+						ThumbnailLoc: "thumb",
+						Title:        "title",
+						Description:  "description",
+						PlayerLoc:    "player",
+					},
+				},
+			},
+		}
+
+		var s sitemapWriter
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:video="http://www.google.com/schemas/sitemap-video/1.1">
+  <url>
+    <loc>one</loc>
+    <video:video>
+      <video:thumbnail_loc>http://www.example.com/thumbs/123.jpg</video:thumbnail_loc>
+      <video:title>Grilling steaks for summer</video:title>
+      <video:description>Alkis shows you how to get perfectly done steaks every time</video:description>
+      <video:content_loc>http://streamserver.example.com/video123.mp4</video:content_loc>
+      <video:player_loc>http://www.example.com/videoplayer.php?video=123&amp;autoplay=1</video:player_loc>
+      <video:duration>600</video:duration>
+      <video:expiration_date>2021-11-05T19:20:30Z</video:expiration_date>
+      <video:publication_date>2007-11-05T19:20:30Z</video:publication_date>
+      <video:family_friendly>no</video:family_friendly>
+      <video:requires_subscription>yes</video:requires_subscription>
+      <video:uploader>GrillyMcGrillserson</video:uploader>
+      <video:live>yes</video:live>
+      <video:tag>steak</video:tag>
+      <video:tag>meat</video:tag>
+    </video:video>
+    <video:video>
+      <video:thumbnail_loc>thumb</video:thumbnail_loc>
+      <video:title>title</video:title>
+      <video:description>description</video:description>
+      <video:player_loc>player</video:player_loc>
+    </video:video>
+  </url>
+</urlset>
+		`)))
+	})
+
+	t.Run("extensions", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{Loc: "one", Extensions: []Extension{geoExtension{Format: "kml"}}},
+			{
+				Loc: "two",
+				Extensions: []Extension{
+					geoExtension{Format: "<&>"},
+					// The namespace of a supported extension is not declared twice.
+					rawExtension{
+						ns:  Namespace{Prefix: "xhtml", URI: xmlnsXhtmlUri},
+						xml: `<xhtml:meta name="a"/>`,
+					},
+				},
+				Alternates: []Alternate{{Hreflang: "en", Href: "two"}},
+			},
+		}
+
+		var s sitemapWriter
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		info, co, err := s.writeUrlsetFile(context.Background(), &out, &in,
+			&UrlEntry{Loc: "zero", Alternates: []Alternate{{Hreflang: "en", Href: "zero"}}})
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(info.size).Should(Equal(out.Len()))
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml" xmlns:geo="http://www.google.com/geo/schemas/sitemap/1.0">
+  <url>
+    <loc>zero</loc>
+    <xhtml:link rel="alternate" hreflang="en" href="zero"/>
+  </url>
+  <url>
+    <loc>one</loc>
+    <geo:geo><geo:format>kml</geo:format></geo:geo>
+  </url>
+  <url>
+    <loc>two</loc>
+    <xhtml:link rel="alternate" hreflang="en" href="two"/>
+    <geo:geo><geo:format>&lt;&amp;&gt;</geo:format></geo:geo>
+    <xhtml:meta name="a"/>
+  </url>
+</urlset>
+		`)))
+	})
+
+	t.Run("extensionsPerFile", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{Loc: "one"},
+			{Loc: "two", Extensions: []Extension{geoExtension{Format: "kml"}}},
+			{Loc: "three"},
+		}
+
+		// Every file declares the namespaces used by its own entries only.
+		s := sitemapWriter{cfg: Writer{MaxEntries: 1, Compact: true}}
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(Equal(&entries[1]))
+		Ω(out.String()).ShouldNot(ContainSubstring("geo"))
+
+		out.Reset()
+		_, co, err = s.writeUrlsetFile(context.Background(), &out, &in, co)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(Equal(&entries[2]))
+		Ω(out.String()).Should(HaveSuffix(
+			`xmlns:geo="http://www.google.com/geo/schemas/sitemap/1.0">` +
+				`<url><loc>two</loc><geo:geo><geo:format>kml</geo:format></geo:geo></url>` +
+				`</urlset>`))
+
+		out.Reset()
+		info, co, err := s.writeUrlsetFile(context.Background(), &out, &in, co)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(info.size).Should(Equal(out.Len()))
+		Ω(out.String()).ShouldNot(ContainSubstring("geo"))
+	})
+
+	t.Run("namespacesAfterWindow", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		// The entries before the one using the geo namespace exceed the
+		// window, hence the header is written already.
+		var entries []UrlEntry
+		for i := 0; i < namespaceWindowSize/40; i++ {
+			entries = append(entries, UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%d", i)})
+		}
+		geo := UrlEntry{Loc: "geo", Extensions: []Extension{geoExtension{Format: "kml"}}}
+		entries = append(entries, geo, UrlEntry{Loc: "last"})
+
+		s := sitemapWriter{cfg: Writer{Compact: true}}
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		info, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(Equal(&geo))
+		Ω(info.entries).Should(Equal(len(entries) - 2))
+		Ω(info.size).Should(Equal(out.Len()))
+		Ω(out.String()).ShouldNot(ContainSubstring("geo"))
+
+		out.Reset()
+		info, co, err = s.writeUrlsetFile(context.Background(), &out, &in, co)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(info.entries).Should(Equal(2))
+		Ω(out.String()).Should(HaveSuffix(
+			`xmlns:geo="http://www.google.com/geo/schemas/sitemap/1.0">` +
+				`<url><loc>geo</loc><geo:geo><geo:format>kml</geo:format></geo:geo></url>` +
+				`<url><loc>last</loc></url></urlset>`))
+	})
+
+	t.Run("namespacesGrowHeader", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		// The entry fits into the file, but not with the namespace it
+		// adds to the header.
+		s := sitemapWriter{cfg: Writer{Compact: true}}
+		var exp bytes.Buffer
+		in := arrayInput{Arr: []UrlEntry{{Loc: "one"}}}
+		_, _, err := s.writeUrlsetFile(context.Background(), &exp, &in, nil)
+		Ω(err).Should(BeNil())
+
+		entries := []UrlEntry{
+			{Loc: "one"},
+			{Loc: "two", Extensions: []Extension{geoExtension{Format: "kml"}}},
+		}
+		s.entryBuf.Reset()
+		Ω(s.writeXmlUrlEntry(&s.entryBuf, &entries[1])).Should(BeNil())
+		s.cfg.MaxFileSize = exp.Len() + s.entryBuf.Len()
+
+		var out bytes.Buffer
+		in = arrayInput{Arr: entries}
+		info, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(Equal(&entries[1]))
+		Ω(info.entries).Should(Equal(1))
+		Ω(out.String()).Should(Equal(exp.String()))
+	})
+
 	t.Run("escaping", func(t *testing.T) {
 		RegisterTestingT(t)
 
@@ -423,7 +1128,7 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 		var s sitemapWriter
 		var out bytes.Buffer
 		in := arrayInput{Arr: entries}
-		co, err := s.writeUrlsetFile(&out, &in, nil)
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
 		Ω(err).Should(BeNil())
 		Ω(co).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
@@ -458,14 +1163,266 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 				Size: 50_000 + 1,
 			}
 
-			var s sitemapWriter
-			co, err := s.writeUrlsetFile(io.Discard, &in, nil)
-			Ω(err).Should(BeNil())
-			Ω(co).Should(Equal(&UrlEntry{
-				Loc:     "http://www.example.com/qweqwe",
-				LastMod: minDate.AddDate(1, 2, 3),
-				Images:  []string{"http://www.example.com/qweqwe/thumb.jpg"},
-			}))
+			var s sitemapWriter
+			_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+			Ω(err).Should(BeNil())
+			Ω(co).Should(Equal(&UrlEntry{
+				Loc:     "http://www.example.com/qweqwe",
+				LastMod: minDate.AddDate(1, 2, 3),
+				Images:  []string{"http://www.example.com/qweqwe/thumb.jpg"},
+			}))
+		})
+
+		t.Run("errInvalidChangefreq", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := arrayInput{Arr: []UrlEntry{{Loc: "one", ChangeFreq: "often"}}}
+
+			var s sitemapWriter
+			_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+			Ω(err).Should(MatchError(`entry "one" has invalid changefreq "often"`))
+			Ω(co).Should(BeNil())
+		})
+
+		t.Run("errInvalidPriority", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := arrayInput{Arr: []UrlEntry{{Loc: "one", Priority: NewPriority(1.5)}}}
+
+			var s sitemapWriter
+			_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+			Ω(err).Should(MatchError(`entry "one" has invalid priority 1.5, ` +
+				`expected a value between 0.0 and 1.0`))
+			Ω(co).Should(BeNil())
+		})
+
+		t.Run("errTooManyImages", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := arrayInput{Arr: []UrlEntry{{
+				Loc:          "one",
+				Images:       make([]string, 600),
+				ImageDetails: make([]Image, 400),
+			}}}
+			in.Arr[0].ImageDetails = append(in.Arr[0].ImageDetails, Image{Loc: "x"})
+
+			var s sitemapWriter
+			_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+			Ω(err).Should(MatchError(`entry "one" has more than 1000 images`))
+			Ω(co).Should(BeNil())
+		})
+
+		t.Run("errImageWithoutLoc", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := arrayInput{Arr: []UrlEntry{{
+				Loc:          "one",
+				ImageDetails: []Image{{Loc: "a"}, {Title: "b"}},
+			}}}
+
+			var s sitemapWriter
+			_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+			Ω(err).Should(MatchError(`entry "one" has an image without location`))
+			Ω(co).Should(BeNil())
+		})
+
+		t.Run("errInvalidExtension", func(t *testing.T) {
+			testCases := []struct {
+				Name      string
+				Writer    Writer
+				Extension Extension
+				Err       string
+			}{
+				{
+					Name:      "noPrefix",
+					Extension: rawExtension{ns: Namespace{URI: "http://example.com/"}},
+					Err:       `entry "one" has an extension without namespace prefix or URI`,
+				},
+				{
+					Name:      "builtinPrefix",
+					Extension: rawExtension{ns: Namespace{Prefix: "image", URI: "http://example.com/"}},
+					Err: `entry "one" has an extension binding namespace prefix "image" ` +
+						`to "http://example.com/", already bound to "` + xmlnsImageUri + `"`,
+				},
+				{
+					Name: "configuredPrefix",
+					Writer: Writer{Namespaces: []Namespace{
+						{Prefix: "geo", URI: "http://example.com/"},
+					}},
+					Extension: geoExtension{},
+					Err: `entry "one" has an extension binding namespace prefix "geo" ` +
+						`to "http://www.google.com/geo/schemas/sitemap/1.0", ` +
+						`already bound to "http://example.com/"`,
+				},
+				{
+					Name:      "writeXml",
+					Extension: rawExtension{ns: geoExtension{}.Namespace(), err: errors.New("no format")},
+					Err:       `entry "one" has invalid extension "geo": no format`,
+				},
+			}
+
+			for _, tc := range testCases {
+				tc := tc
+				t.Run(tc.Name, func(t *testing.T) {
+					RegisterTestingT(t)
+
+					in := arrayInput{Arr: []UrlEntry{
+						{Loc: "one", Extensions: []Extension{tc.Extension}},
+					}}
+					s := sitemapWriter{cfg: tc.Writer}
+					_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+					Ω(err).Should(MatchError(tc.Err))
+					Ω(errors.Is(err, ErrInvalidEntry)).Should(BeTrue())
+					Ω(co).Should(BeNil())
+				})
+			}
+
+			t.Run("prefixRebound", func(t *testing.T) {
+				RegisterTestingT(t)
+
+				in := arrayInput{Arr: []UrlEntry{
+					{Loc: "one", Extensions: []Extension{geoExtension{}}},
+					{Loc: "two", Extensions: []Extension{
+						rawExtension{ns: Namespace{Prefix: "geo", URI: "http://example.com/"}},
+					}},
+				}}
+				var s sitemapWriter
+				_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+				Ω(err).Should(MatchError(`entry "two" has an extension binding namespace ` +
+					`prefix "geo" to "http://example.com/", already bound to ` +
+					`"http://www.google.com/geo/schemas/sitemap/1.0"`))
+				Ω(co).Should(BeNil())
+			})
+		})
+
+		t.Run("errInvalidAlternate", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := arrayInput{Arr: []UrlEntry{{
+				Loc:        "one",
+				Alternates: []Alternate{{Hreflang: "en", Href: "one"}, {Href: "two"}},
+			}}}
+
+			var s sitemapWriter
+			_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+			Ω(err).Should(MatchError(`entry "one" has an alternate without hreflang or href`))
+			Ω(co).Should(BeNil())
+		})
+
+		t.Run("errInvalidNews", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			valid := News{
+				Publication:     NewsPublication{Name: "Times", Language: "en"},
+				PublicationDate: time.Date(2008, 12, 23, 0, 0, 0, 0, time.UTC),
+				Title:           "Title",
+			}
+			testCases := []struct {
+				Modify func(n *News)
+				Err    string
+			}{
+				{
+					Modify: func(n *News) { n.Publication.Name = "" },
+					Err:    `entry "one" has no news publication name`,
+				},
+				{
+					Modify: func(n *News) { n.Publication.Language = "" },
+					Err:    `entry "one" has no news publication language`,
+				},
+				{
+					Modify: func(n *News) { n.PublicationDate = time.Time{} },
+					Err:    `entry "one" has no news publication date`,
+				},
+				{
+					Modify: func(n *News) { n.Title = "" },
+					Err:    `entry "one" has no news title`,
+				},
+			}
+
+			for _, tc := range testCases {
+				news := valid
+				tc.Modify(&news)
+				in := arrayInput{Arr: []UrlEntry{{Loc: "one", News: &news}}}
+
+				var s sitemapWriter
+				_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+				Ω(err).Should(MatchError(tc.Err))
+				Ω(co).Should(BeNil())
+			}
+		})
+
+		t.Run("errInvalidVideo", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			valid := Video{
+				ThumbnailLoc: "thumb",
+				Title:        "title",
+				Description:  "description",
+				ContentLoc:   "content",
+			}
+			testCases := []struct {
+				Modify func(v *Video)
+				Err    string
+			}{
+				{
+					Modify: func(v *Video) { v.ThumbnailLoc = "" },
+					Err:    "no thumbnail location",
+				},
+				{
+					Modify: func(v *Video) { v.Title = "" },
+					Err:    "no title",
+				},
+				{
+					Modify: func(v *Video) { v.Description = "" },
+					Err:    "no description",
+				},
+				{
+					Modify: func(v *Video) { v.Description = strings.Repeat("ü", 2_049) },
+					Err:    "description is longer than 2048 characters",
+				},
+				{
+					Modify: func(v *Video) { v.ContentLoc = "" },
+					Err:    "neither content nor player location",
+				},
+				{
+					Modify: func(v *Video) { v.Duration = time.Millisecond },
+					Err:    "duration 1ms is out of range [1s, 8h0m0s]",
+				},
+				{
+					Modify: func(v *Video) { v.Duration = 8*time.Hour + time.Second },
+					Err:    "duration 8h0m1s is out of range [1s, 8h0m0s]",
+				},
+				{
+					Modify: func(v *Video) { v.Tags = make([]string, 33) },
+					Err:    "more than 32 tags",
+				},
+			}
+
+			for _, tc := range testCases {
+				video := valid
+				tc.Modify(&video)
+				in := arrayInput{Arr: []UrlEntry{{
+					Loc:    "one",
+					Videos: []Video{valid, video},
+				}}}
+
+				var s sitemapWriter
+				_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+				Ω(err).Should(MatchError(`entry "one" has invalid video 1: ` + tc.Err))
+				Ω(co).Should(BeNil())
+			}
+		})
+
+		t.Run("errEntryTooLarge", func(t *testing.T) {
+			RegisterTestingT(t)
+
+			in := arrayInput{Arr: []UrlEntry{{Loc: "http://www.example.com/qweqwe"}}}
+
+			s := sitemapWriter{cfg: Writer{MaxFileSize: 150}}
+			_, co, err := s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
+			Ω(err).Should(MatchError(`entry "http://www.example.com/qweqwe" ` +
+				`does not fit into a urlset file of 150 bytes`))
+			Ω(co).Should(BeNil())
 		})
 
 		t.Run("failingWriter", func(t *testing.T) {
@@ -481,12 +1438,61 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 			}
 
 			var s sitemapWriter
-			co, err := s.writeUrlsetFile(&failingWriter{}, &in, nil)
-			Ω(err).Should(MatchError("failingWriter error"))
+			_, co, err := s.writeUrlsetFile(context.Background(), &failingWriter{}, &in, nil)
+			Ω(err).Should(MatchError(
+				"sitemap: writing urlset file 0 failed after 0 entries: failingWriter error"))
 			Ω(co).Should(BeNil())
 		})
 	})
 
+	t.Run("maxFileSize", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{Loc: "one"},
+			{Loc: "two"},
+			{Loc: "six"},
+		}
+		entrySize := len("  <url>\n    <loc>one</loc>\n  </url>\n")
+
+		s := sitemapWriter{
+			cfg: Writer{
+				MaxFileSize: len(urlsetHeaderOpen) + len(urlsetHeaderClose) +
+					2*entrySize + len(urlsetFooter),
+			},
+		}
+		var out bytes.Buffer
+		in := arrayInput{Arr: entries}
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(Equal(&UrlEntry{Loc: "six"}))
+		Ω(out.Len()).Should(Equal(s.cfg.MaxFileSize))
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>one</loc>
+  </url>
+  <url>
+    <loc>two</loc>
+  </url>
+</urlset>
+		`)))
+
+		out.Reset()
+		_, co, err = s.writeUrlsetFile(context.Background(), &out, &in, co)
+		Ω(err).Should(BeNil())
+		Ω(co).Should(BeNil())
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>six</loc>
+  </url>
+</urlset>
+		`)))
+	})
+
 	t.Run("carryOver", func(t *testing.T) {
 		RegisterTestingT(t)
 
@@ -508,12 +1514,12 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 		var s sitemapWriter
 		var out bytes.Buffer
 		in := arrayInput{Arr: entries}
-		co, err := s.writeUrlsetFile(&out, &in, &carryOver)
+		_, co, err := s.writeUrlsetFile(context.Background(), &out, &in, &carryOver)
 		Ω(err).Should(BeNil())
 		Ω(co).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
   <url>
     <loc>co</loc>
     <lastmod>2001-01-01T00:00:00Z</lastmod>
@@ -529,6 +1535,118 @@ func TestSitemapWriter_WriteUrlsetFile(t *testing.T) {
 		`)))
 	})
 
+	t.Run("maxEntries", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		s := sitemapWriter{cfg: Writer{MaxEntries: 2}}
+		var out bytes.Buffer
+		in := arrayInput{Arr: []UrlEntry{{Loc: "one"}, {Loc: "two"}, {Loc: "six"}}}
+		info, co, err := s.writeUrlsetFile(context.Background(), &out, &in, nil)
+		Ω(err).Should(BeNil())
+		Ω(info.entries).Should(Equal(2))
+		Ω(co).Should(Equal(&UrlEntry{Loc: "six"}))
+
+		// The protocol limit cannot be exceeded.
+		s.cfg.MaxEntries = maxSitemapCap + 1
+		Ω(s.cfg.maxEntries()).Should(Equal(maxSitemapCap))
+	})
+
+	t.Run("minLastMod", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{Loc: "one", LastMod: time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)},
+			{Loc: "two", LastMod: time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}
+		s := sitemapWriter{cfg: Writer{
+			MinLastMod: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		}}
+		var out bytes.Buffer
+		info, _, err := s.writeUrlsetFile(context.Background(), &out,
+			&arrayInput{Arr: entries}, nil)
+		Ω(err).Should(BeNil())
+		Ω(info.minLastMod).Should(Equal(entries[0].LastMod))
+		Ω(out.String()).Should(ContainSubstring("<lastmod>1999-12-31T23:59:59Z</lastmod>"))
+
+		s.cfg.MinLastMod = time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+		out.Reset()
+		info, _, err = s.writeUrlsetFile(context.Background(), &out,
+			&arrayInput{Arr: entries}, nil)
+		Ω(err).Should(BeNil())
+		Ω(info.lastMod.IsZero()).Should(BeTrue())
+		Ω(out.String()).ShouldNot(ContainSubstring("<lastmod>"))
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		entries := []UrlEntry{
+			{
+				Loc:        "one",
+				LastMod:    time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC),
+				Alternates: []Alternate{{Hreflang: "fr", Href: "un"}},
+				Images:     []string{"1.jpg"},
+			},
+			{Loc: " two "},
+		}
+		s := sitemapWriter{cfg: Writer{Compact: true}}
+		var out bytes.Buffer
+		info, _, err := s.writeUrlsetFile(context.Background(), &out,
+			&arrayInput{Arr: entries}, nil)
+		Ω(err).Should(BeNil())
+		Ω(info.size).Should(Equal(out.Len()))
+		Ω(out.String()).Should(Equal(`<?xml version="1.0" encoding="UTF-8"?>` +
+			`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" ` +
+			`xmlns:image="http://www.google.com/schemas/sitemap-image/1.1" ` +
+			`xmlns:xhtml="http://www.w3.org/1999/xhtml">` +
+			`<url><loc>one</loc><lastmod>2001-01-01T00:00:00Z</lastmod>` +
+			`<xhtml:link rel="alternate" hreflang="fr" href="un"/>` +
+			`<image:image><image:loc>1.jpg</image:loc></image:image></url>` +
+			`<url><loc> two </loc></url>` +
+			`</urlset>`))
+	})
+
+	t.Run("namespaces", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		s := sitemapWriter{cfg: Writer{Namespaces: []Namespace{
+			{Prefix: "geo", URI: "http://www.google.com/geo/schemas/sitemap/1.0"},
+			{Prefix: "q", URI: `"&`},
+		}}}
+		var out bytes.Buffer
+		info, _, err := s.writeUrlsetFile(context.Background(), &out,
+			&arrayInput{Arr: []UrlEntry{{Loc: "one"}}}, nil)
+		Ω(err).Should(BeNil())
+		Ω(info.size).Should(Equal(out.Len()))
+		Ω(out.String()).Should(HavePrefix(xml.Header +
+			`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" ` +
+			`xmlns:geo="http://www.google.com/geo/schemas/sitemap/1.0" ` +
+			`xmlns:q="&#34;&amp;">` + "\n"))
+	})
+
+	t.Run("builtinNamespaces", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		s := sitemapWriter{cfg: Writer{Compact: true, Namespaces: []Namespace{
+			{Prefix: "video", URI: xmlnsVideoUri},
+			{Prefix: "geo", URI: "http://www.google.com/geo/schemas/sitemap/1.0"},
+		}}}
+		var out bytes.Buffer
+		info, _, err := s.writeUrlsetFile(context.Background(), &out,
+			&arrayInput{Arr: []UrlEntry{
+				{Loc: "one"},
+				{Loc: "two", Extensions: []Extension{geoExtension{Format: "kml"}}},
+			}}, nil)
+		Ω(err).Should(BeNil())
+		Ω(info.size).Should(Equal(out.Len()))
+		Ω(out.String()).Should(Equal(`<?xml version="1.0" encoding="UTF-8"?>` +
+			`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" ` +
+			`xmlns:video="http://www.google.com/schemas/sitemap-video/1.1" ` +
+			`xmlns:geo="http://www.google.com/geo/schemas/sitemap/1.0">` +
+			`<url><loc>one</loc></url>` +
+			`<url><loc>two</loc><geo:geo><geo:format>kml</geo:format></geo:geo></url>` +
+			`</urlset>`))
+	})
 }
 
 func TestSitemapWriter_WriteIndexFile(t *testing.T) {
@@ -541,7 +1659,8 @@ func TestSitemapWriter_WriteIndexFile(t *testing.T) {
 
 		var s sitemapWriter
 		var out bytes.Buffer
-		Ω(s.writeIndexFile(&out, &arrayInput{CustomUrlsetUrl: emptyUrl}, 0)).Should(BeNil())
+		files := resolveUrlsets(&arrayInput{CustomUrlsetUrl: emptyUrl}, nil)
+		Ω(s.writeIndexFile(&out, files)).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
 <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
@@ -554,7 +1673,8 @@ func TestSitemapWriter_WriteIndexFile(t *testing.T) {
 
 		var s sitemapWriter
 		var out bytes.Buffer
-		Ω(s.writeIndexFile(&out, &arrayInput{}, 3)).Should(BeNil())
+		files := resolveUrlsets(&arrayInput{}, make([]urlsetInfo, 3))
+		Ω(s.writeIndexFile(&out, files)).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
 <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
@@ -580,7 +1700,9 @@ func TestSitemapWriter_WriteIndexFile(t *testing.T) {
 
 		var s sitemapWriter
 		var out bytes.Buffer
-		Ω(s.writeIndexFile(&out, &arrayInput{CustomUrlsetUrl: simpleUrl}, 4)).Should(BeNil())
+		files := resolveUrlsets(&arrayInput{CustomUrlsetUrl: simpleUrl},
+			make([]urlsetInfo, 4))
+		Ω(s.writeIndexFile(&out, files)).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
 <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
@@ -600,6 +1722,90 @@ func TestSitemapWriter_WriteIndexFile(t *testing.T) {
 		`)))
 	})
 
+	t.Run("lastmod", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		files := []urlsetInfo{
+			{lastMod: time.Date(2015, 7, 22, 15, 48, 2, 0, time.UTC)},
+			{},
+			{lastMod: time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)},
+		}
+
+		var s sitemapWriter
+		var out bytes.Buffer
+		Ω(s.writeIndexFile(&out, resolveUrlsets(&arrayInput{}, files))).Should(BeNil())
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>urlset no. 1</loc>
+    <lastmod>2015-07-22T15:48:02Z</lastmod>
+  </sitemap>
+  <sitemap>
+    <loc>urlset no. 2</loc>
+  </sitemap>
+  <sitemap>
+    <loc>urlset no. 3</loc>
+  </sitemap>
+</sitemapindex>
+		`)))
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		files := []urlsetInfo{
+			{lastMod: time.Date(2015, 7, 22, 15, 48, 2, 0, time.UTC)},
+			{},
+		}
+
+		s := sitemapWriter{cfg: Writer{Compact: true}}
+		var out bytes.Buffer
+		Ω(s.writeIndexFile(&out, resolveUrlsets(&arrayInput{}, files))).Should(BeNil())
+		Ω(out.String()).Should(Equal(`<?xml version="1.0" encoding="UTF-8"?>` +
+			`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+			`<sitemap><loc>urlset no. 1</loc><lastmod>2015-07-22T15:48:02Z</lastmod></sitemap>` +
+			`<sitemap><loc>urlset no. 2</loc></sitemap>` +
+			`</sitemapindex>`))
+	})
+
+	t.Run("lastmodOverride", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		files := []urlsetInfo{
+			{lastMod: time.Date(2015, 7, 22, 15, 48, 2, 0, time.UTC)},
+			{lastMod: time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{},
+		}
+		in := lastModInput{
+			LastMods: map[int]time.Time{
+				1: time.Date(2020, 3, 15, 12, 13, 14, 0, time.UTC),
+				2: time.Date(2021, 7, 31, 23, 59, 59, 0, time.UTC),
+			},
+		}
+
+		var s sitemapWriter
+		var out bytes.Buffer
+		Ω(s.writeIndexFile(&out, resolveUrlsets(&in, files))).Should(BeNil())
+		Ω(out.String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>urlset no. 1</loc>
+    <lastmod>2015-07-22T15:48:02Z</lastmod>
+  </sitemap>
+  <sitemap>
+    <loc>urlset no. 2</loc>
+    <lastmod>2020-03-15T12:13:14Z</lastmod>
+  </sitemap>
+  <sitemap>
+    <loc>urlset no. 3</loc>
+    <lastmod>2021-07-31T23:59:59Z</lastmod>
+  </sitemap>
+</sitemapindex>
+		`)))
+	})
+
 	t.Run("escaping", func(t *testing.T) {
 		RegisterTestingT(t)
 
@@ -618,7 +1824,9 @@ func TestSitemapWriter_WriteIndexFile(t *testing.T) {
 
 		var s sitemapWriter
 		var out bytes.Buffer
-		Ω(s.writeIndexFile(&out, &arrayInput{CustomUrlsetUrl: fancyUrl}, 5)).Should(BeNil())
+		files := resolveUrlsets(&arrayInput{CustomUrlsetUrl: fancyUrl},
+			make([]urlsetInfo, 5))
+		Ω(s.writeIndexFile(&out, files)).Should(BeNil())
 		Ω(out.String()).Should(Equal(strings.TrimSpace(`
 <?xml version="1.0" encoding="UTF-8"?>
 <sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
@@ -652,12 +1860,127 @@ func TestSitemapWriter_WriteIndexFile(t *testing.T) {
 			}
 
 			var s sitemapWriter
-			Ω(s.writeIndexFile(&failingWriter{}, &in, 100)).
-				Should(MatchError("failingWriter error"))
+			files := resolveUrlsets(&in, make([]urlsetInfo, 100))
+			Ω(s.writeIndexFile(&failingWriter{}, files)).
+				Should(MatchError(
+					"sitemap: writing index file failed after 0 entries: failingWriter error"))
 		})
 	})
 }
 
+func TestSitemapWriter_SplitIndex(t *testing.T) {
+	files := func(n int) []urlsetInfo {
+		return resolveUrlsets(&arrayInput{}, make([]urlsetInfo, n))
+	}
+	lens := func(parts [][]urlsetInfo) []int {
+		var res []int
+		for _, p := range parts {
+			res = append(res, len(p))
+		}
+		return res
+	}
+	// "<sitemap><loc>urlset no. N</loc></sitemap>" written on 4 lines
+	sitemapSize := len("\n  <sitemap>\n    <loc>urlset no. 1</loc>\n  </sitemap>")
+
+	t.Run("empty", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var s sitemapWriter
+		parts := s.splitIndex(nil, 2, maxSitemapSize)
+		Ω(parts).Should(HaveLen(1))
+		Ω(parts[0]).Should(BeEmpty())
+	})
+
+	t.Run("count", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var s sitemapWriter
+		Ω(lens(s.splitIndex(files(2), 2, maxSitemapSize))).Should(Equal([]int{2}))
+		Ω(lens(s.splitIndex(files(5), 2, maxSitemapSize))).Should(Equal([]int{2, 2, 1}))
+	})
+
+	t.Run("size", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var s sitemapWriter
+		headerSize := len(indexHeader) + len(indexFooter)
+		Ω(lens(s.splitIndex(files(5), 10, headerSize+3*sitemapSize))).
+			Should(Equal([]int{3, 2}))
+		Ω(lens(s.splitIndex(files(5), 10, headerSize+3*sitemapSize-1))).
+			Should(Equal([]int{2, 2, 1}))
+
+		// An index file lists at least one file.
+		Ω(lens(s.splitIndex(files(2), 10, 1))).Should(Equal([]int{1, 1}))
+	})
+}
+
+func TestWriteAll_SplitIndex(t *testing.T) {
+	// Every entry takes a whole urlset file.
+	in := dynamicInput{
+		Size: maxIndexCap + 2,
+		CustomEntry: func(idx int) *UrlEntry {
+			return &UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%d/%s", idx,
+				strings.Repeat("a", 500))}
+		},
+		CustomUrlsetUrl: func(idx int) string {
+			return fmt.Sprintf("urlset %03d", idx)
+		},
+	}
+	w := Writer{MaxFileSize: 1_000}
+
+	t.Run("result", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in.Reset()
+		var out indexesOutput
+		r, err := w.WriteAllResult(context.Background(), &out, &in)
+		Ω(err).Should(BeNil())
+		Ω(r.Urlsets).Should(HaveLen(maxIndexCap + 2))
+		Ω(out.nsitemaps).Should(Equal(maxIndexCap + 2))
+		Ω(out.indexes).Should(HaveLen(2))
+		Ω(r.Indexes).Should(Equal([]IndexResult{
+			{
+				Index:    0,
+				Url:      "index 0",
+				Sitemaps: maxIndexCap,
+				Size:     out.indexes[0].Len(),
+			},
+			{
+				Index:    1,
+				Url:      "index 1",
+				Sitemaps: 2,
+				Size:     out.indexes[1].Len(),
+			},
+		}))
+		Ω(r.IndexUrls()).Should(Equal([]string{"index 0", "index 1"}))
+
+		Ω(out.indexes[1].String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>urlset 50000</loc>
+  </sitemap>
+  <sitemap>
+    <loc>urlset 50001</loc>
+  </sitemap>
+</sitemapindex>
+		`)))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		in.Reset()
+		out := indexesOutput{FailIndex: 1}
+		err := w.WriteAll(&out, &in)
+		Ω(err).Should(MatchError(
+			"sitemap: writing index file 1 failed after 0 entries: failingWriter error"))
+		var werr *WriteError
+		Ω(errors.As(err, &werr)).Should(BeTrue())
+		Ω(werr.File).Should(Equal(1))
+	})
+}
+
 func assertOutput(out *bufferOuput, expSize int) {
 	type sitemapList struct {
 		Locs []string `xml:"sitemap>loc"`
@@ -721,6 +2044,25 @@ func (a *arrayInput) GetUrlsetUrl(idx int) string {
 	return fmt.Sprintf("urlset no. %d", idx+1)
 }
 
+// resolveUrlsets resolves the given urlset files as if they were written
+// from the given input.
+func resolveUrlsets(in Input, files []urlsetInfo) []urlsetInfo {
+	var s sitemapWriter
+	for i := range files {
+		s.resolveUrlsetInfo(in, nil, i, &files[i])
+	}
+	return files
+}
+
+type lastModInput struct {
+	arrayInput
+	LastMods map[int]time.Time
+}
+
+func (a *lastModInput) GetUrlsetLastMod(idx int) time.Time {
+	return a.LastMods[idx]
+}
+
 type failiingOutput struct {
 	FailIndex  bool
 	FailUrlset bool
@@ -742,6 +2084,44 @@ func (o *failiingOutput) Urlset() io.Writer {
 	return io.Discard
 }
 
+// geoExtension is an Extension writing the element of the Google geo sitemap
+// extension.
+type geoExtension struct {
+	Format string
+}
+
+func (geoExtension) Namespace() Namespace {
+	return Namespace{Prefix: "geo", URI: "http://www.google.com/geo/schemas/sitemap/1.0"}
+}
+
+func (e geoExtension) WriteXml(w io.Writer) error {
+	_, _ = io.WriteString(w, "<geo:geo><geo:format>")
+	_ = xml.EscapeText(w, []byte(e.Format))
+	_, err := io.WriteString(w, "</geo:format></geo:geo>")
+	return err
+}
+
+// rawExtension is an Extension writing the given XML, or failing with the
+// given error.
+type rawExtension struct {
+	ns  Namespace
+	xml string
+	err error
+}
+
+func (e rawExtension) Namespace() Namespace {
+	return e.ns
+}
+
+func (e rawExtension) WriteXml(w io.Writer) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	_, err := io.WriteString(w, e.xml)
+	return err
+}
+
 type failingWriter struct{}
 
 func (failingWriter) Write(bs []byte) (int, error) {
@@ -762,6 +2142,91 @@ func (o *bufferOuput) Urlset() io.Writer {
 	return &o.sitemaps[len(o.sitemaps)-1]
 }
 
+// indexesOutput keeps every index file in a separate buffer and discards
+// urlset files. The writer of the index file at FailIndex fails, unless it
+// is zero.
+type indexesOutput struct {
+	FailIndex int
+
+	indexes   []bytes.Buffer
+	nsitemaps int
+}
+
+func (o *indexesOutput) Index() io.Writer {
+	if o.FailIndex > 0 && len(o.indexes) == o.FailIndex {
+		return failingWriter{}
+	}
+
+	o.indexes = append(o.indexes, bytes.Buffer{})
+	return &o.indexes[len(o.indexes)-1]
+}
+
+func (o *indexesOutput) Urlset() io.Writer {
+	o.nsitemaps++
+	return io.Discard
+}
+
+func (o *indexesOutput) GetIndexUrl(idx int) string {
+	return fmt.Sprintf("index %d", idx)
+}
+
+// finalizingOutput records the order in which the files are finalized.
+// The writers are taken from Output when it is set, from the embedded
+// buffers otherwise. FailUrlset is the number of the urlset file, starting
+// from 1, whose finalization fails.
+type finalizingOutput struct {
+	bufferOuput
+	Output     Output
+	FailUrlset int
+	FailIndex  bool
+
+	nsitemaps int
+	finalized []string
+}
+
+func (o *finalizingOutput) Index() io.Writer {
+	w := finalizingWriter{Writer: o.bufferOuput.Index(), name: "index", out: o}
+	if o.Output != nil {
+		w.Writer = o.Output.Index()
+	}
+	if o.FailIndex {
+		w.err = errors.New("finalize index")
+	}
+	return &w
+}
+
+func (o *finalizingOutput) Urlset() io.Writer {
+	w := finalizingWriter{
+		Writer: o.bufferOuput.Urlset(),
+		name:   fmt.Sprintf("urlset %d", o.nsitemaps),
+		out:    o,
+	}
+	if o.Output != nil {
+		w.Writer = o.Output.Urlset()
+	}
+	if o.FailUrlset == o.nsitemaps+1 {
+		w.err = fmt.Errorf("finalize %s", w.name)
+	}
+	o.nsitemaps++
+	return &w
+}
+
+type finalizingWriter struct {
+	io.Writer
+	name string
+	err  error
+	out  *finalizingOutput
+}
+
+func (w *finalizingWriter) Finalize() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.out.finalized = append(w.out.finalized, w.name)
+	return nil
+}
+
 type dynamicInput struct {
 	Size            int
 	DefaultEntry    UrlEntry
@@ -850,7 +2315,7 @@ func BenchmarkWriteUrlset(b *testing.B) {
 			in.Size = size
 			for n := 0; n < b.N; n++ {
 				in.Reset()
-				_, _ = s.writeUrlsetFile(io.Discard, &in, nil)
+				_, _, _ = s.writeUrlsetFile(context.Background(), io.Discard, &in, nil)
 			}
 		})
 	}
@@ -872,9 +2337,9 @@ func BenchmarkWriteIndex(b *testing.B) {
 	for p := 0; p < 6; p++ {
 		nfiles := int(math.Pow10(p))
 		b.Run(strconv.Itoa(nfiles), func(b *testing.B) {
+			files := resolveUrlsets(&in, make([]urlsetInfo, nfiles))
 			for n := 0; n < b.N; n++ {
-				in.Reset()
-				_ = s.writeIndexFile(io.Discard, &in, nfiles)
+				_ = s.writeIndexFile(io.Discard, files)
 			}
 		})
 	}
@@ -970,6 +2435,28 @@ func TestSitemapWriter_writeXmlTime(t *testing.T) {
 	}
 }
 
+func TestSitemapWriter_writeXmlPriority(t *testing.T) {
+	RegisterTestingT(t)
+
+	testCases := []struct {
+		In  float64
+		Out string
+	}{
+		{In: 0, Out: "0.0"},
+		{In: 0.1, Out: "0.1"},
+		{In: 0.5, Out: "0.5"},
+		{In: 0.85, Out: "0.85"},
+		{In: 1, Out: "1.0"},
+	}
+
+	var s sitemapWriter
+	for _, tc := range testCases {
+		var b bytes.Buffer
+		s.writeXmlPriority(&b, tc.In)
+		Ω(b.String()).Should(Equal(tc.Out), tc.Out)
+	}
+}
+
 func BenchmarkSitemapWriter_writeXmlTime(b *testing.B) {
 	b.Run("utc", func(b *testing.B) {
 		var s sitemapWriter
@@ -0,0 +1,91 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// EntryError describes an entry with an invalid URL, see Writer.ValidateUrls.
+type EntryError struct {
+	// Loc is the location of the entry.
+	Loc string
+	// Field names the invalid URL, e.g. "loc", "alternate 0" or "image 2".
+	Field string
+	// Err describes the problem.
+	Err error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("entry %q has invalid %s: %v", e.Loc, e.Field, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// validateUrls checks the URLs of the given entry, see Writer.ValidateUrls.
+func (s *sitemapWriter) validateUrls(e *UrlEntry) *EntryError {
+	if err := validateUrl(e.Loc, s.host); err != nil {
+		return &EntryError{Loc: e.Loc, Field: "loc", Err: err}
+	}
+
+	for i := range e.Alternates {
+		if err := validateUrl(e.Alternates[i].Href, ""); err != nil {
+			return &EntryError{Loc: e.Loc, Field: fmt.Sprintf("alternate %d", i), Err: err}
+		}
+	}
+
+	for i := range e.Images {
+		if err := validateUrl(e.Images[i], ""); err != nil {
+			return &EntryError{Loc: e.Loc, Field: fmt.Sprintf("image %d", i), Err: err}
+		}
+	}
+	for i := range e.ImageDetails {
+		if err := validateUrl(e.ImageDetails[i].Loc, ""); err != nil {
+			field := fmt.Sprintf("image %d", len(e.Images)+i)
+			return &EntryError{Loc: e.Loc, Field: field, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// validateUrl checks the given URL is an absolute http or https URL within
+// the length limit, on the given host unless it is empty.
+func validateUrl(rawUrl, host string) error {
+	if rawUrl == "" {
+		return errors.New("URL is empty")
+	}
+	if utf8.RuneCountInString(rawUrl) > maxUrlLen {
+		return fmt.Errorf("URL is longer than %d characters", maxUrlLen)
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return errors.Unwrap(err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL scheme %q is not http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("URL is not absolute")
+	}
+	if host != "" && !strings.EqualFold(u.Host, host) {
+		return fmt.Errorf("URL host %q is not the sitemap host %q", u.Host, host)
+	}
+
+	return nil
+}
+
+// urlHost returns the host of the given absolute URL, or an empty string.
+func urlHost(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil || !u.IsAbs() {
+		return ""
+	}
+
+	return u.Host
+}
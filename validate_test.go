@@ -0,0 +1,314 @@
+package sitemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestValidateUrl(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Url  string
+		Host string
+		Err  string
+	}{
+		{
+			Name: "valid",
+			Url:  "http://goiguide.com/a?b=c#d",
+		},
+		{
+			Name: "https",
+			Url:  "https://goiguide.com/",
+			Host: "goiguide.com",
+		},
+		{
+			Name: "hostCase",
+			Url:  "https://GoiGuide.com/",
+			Host: "goiguide.com",
+		},
+		{
+			Name: "maxLength",
+			Url:  "http://goiguide.com/" + strings.Repeat("ü", 2_048-20),
+		},
+		{
+			Name: "empty",
+			Url:  "",
+			Err:  "URL is empty",
+		},
+		{
+			Name: "tooLong",
+			Url:  "http://goiguide.com/" + strings.Repeat("a", 2_048-19),
+			Err:  "URL is longer than 2048 characters",
+		},
+		{
+			Name: "relative",
+			Url:  "/a/b",
+			Err:  `URL scheme "" is not http or https`,
+		},
+		{
+			Name: "noHost",
+			Url:  "http:///a/b",
+			Err:  "URL is not absolute",
+		},
+		{
+			Name: "scheme",
+			Url:  "ftp://goiguide.com/a",
+			Err:  `URL scheme "ftp" is not http or https`,
+		},
+		{
+			Name: "otherHost",
+			Url:  "http://www.goiguide.com/a",
+			Host: "goiguide.com",
+			Err:  `URL host "www.goiguide.com" is not the sitemap host "goiguide.com"`,
+		},
+		{
+			Name: "malformed",
+			Url:  "http://goiguide.com/%zz",
+			Err:  `invalid URL escape "%zz"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			RegisterTestingT(t)
+
+			err := validateUrl(tc.Url, tc.Host)
+			if tc.Err == "" {
+				Ω(err).Should(BeNil())
+			} else {
+				Ω(err).Should(MatchError(tc.Err))
+			}
+		})
+	}
+}
+
+func TestWriter_ValidateUrls(t *testing.T) {
+	entries := func() []UrlEntry {
+		return []UrlEntry{
+			{Loc: "http://goiguide.com/0"},
+			{Loc: "/1"},
+			{
+				Loc:          "http://goiguide.com/2",
+				Images:       []string{"http://cdn.goiguide.com/1.jpg"},
+				ImageDetails: []Image{{Loc: "2.jpg"}},
+			},
+			{
+				Loc: "http://goiguide.com/3",
+				Alternates: []Alternate{
+					{Hreflang: "en", Href: "http://goiguide.com/3"},
+					{Hreflang: "fr", Href: "goiguide.com/fr/3"},
+				},
+			},
+			{Loc: "http://other.com/4"},
+			{Loc: "http://goiguide.com/5", Images: []string{"http://cdn.goiguide.com/5.jpg"}},
+		}
+	}
+	urlsetUrl := func(idx int) string {
+		return fmt.Sprintf("http://goiguide.com/sitemap-%d.xml", idx)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var out bufferOuput
+		Ω(WriteAll(&out, &arrayInput{Arr: entries()})).Should(BeNil())
+		var nurls int
+		for i := range out.sitemaps {
+			nurls += strings.Count(out.sitemaps[i].String(), "<url>")
+		}
+		Ω(nurls).Should(Equal(6))
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		w := Writer{ValidateUrls: true}
+		var out bufferOuput
+		err := w.WriteAll(&out, &arrayInput{Arr: entries(), CustomUrlsetUrl: urlsetUrl})
+		Ω(err).Should(MatchError(`entry "/1" has invalid loc: ` +
+			`URL scheme "" is not http or https`))
+
+		var entryErr *EntryError
+		Ω(errors.As(err, &entryErr)).Should(BeTrue())
+		Ω(entryErr.Loc).Should(Equal("/1"))
+		Ω(entryErr.Field).Should(Equal("loc"))
+		Ω(out.index.Len()).Should(Equal(0))
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		for _, workers := range []int{0, 2} {
+			workers := workers
+			t.Run(fmt.Sprintf("workers%d", workers), func(t *testing.T) {
+				RegisterTestingT(t)
+
+				var invalid []string
+				w := Writer{
+					ValidateUrls: true,
+					SkipInvalid:  true,
+					OnInvalid: func(err *EntryError) {
+						invalid = append(invalid, err.Error())
+					},
+					Workers: workers,
+				}
+				var out bufferOuput
+				in := arrayInput{Arr: entries(), CustomUrlsetUrl: urlsetUrl}
+				Ω(w.WriteAll(&out, &in)).Should(BeNil())
+
+				Ω(invalid).Should(Equal([]string{
+					`entry "/1" has invalid loc: URL scheme "" is not http or https`,
+					`entry "http://goiguide.com/2" has invalid image 1: URL scheme "" is not http or https`,
+					`entry "http://goiguide.com/3" has invalid alternate 1: URL scheme "" is not http or https`,
+					`entry "http://other.com/4" has invalid loc: URL host "other.com" is not the sitemap host "goiguide.com"`,
+				}))
+				Ω(out.sitemaps).Should(HaveLen(1))
+				Ω(out.sitemaps[0].String()).Should(Equal(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url>
+    <loc>http://goiguide.com/0</loc>
+  </url>
+  <url>
+    <loc>http://goiguide.com/5</loc>
+    <image:image>
+      <image:loc>http://cdn.goiguide.com/5.jpg</image:loc>
+    </image:image>
+  </url>
+</urlset>
+				`)))
+			})
+		}
+	})
+
+	t.Run("skipWithNamespaces", func(t *testing.T) {
+		for _, workers := range []int{0, 2} {
+			workers := workers
+			t.Run(fmt.Sprintf("workers%d", workers), func(t *testing.T) {
+				RegisterTestingT(t)
+
+				// The namespaces of the file are declared by the first
+				// entry written, not by the skipped one.
+				w := Writer{ValidateUrls: true, SkipInvalid: true, Workers: workers}
+				var out bufferOuput
+				r, err := w.WriteAllResult(context.Background(), &out, &arrayInput{
+					Arr: []UrlEntry{
+						{Loc: "relative"},
+						{Loc: "http://goiguide.com/0", Videos: []Video{{
+							ThumbnailLoc: "http://goiguide.com/0.jpg",
+							Title:        "Tour",
+							Description:  "A tour",
+							ContentLoc:   "http://goiguide.com/0.mp4",
+						}}},
+						{Loc: "http://goiguide.com/1"},
+						{Loc: "http://goiguide.com/2"},
+					},
+					CustomUrlsetUrl: urlsetUrl,
+				})
+				Ω(err).Should(BeNil())
+				Ω(r.Entries()).Should(Equal(3))
+				Ω(out.sitemaps).Should(HaveLen(1))
+				Ω(out.sitemaps[0].String()).Should(ContainSubstring(string(xmlnsVideo)))
+				Ω(strings.Count(out.sitemaps[0].String(), "<url>")).Should(Equal(3))
+			})
+		}
+	})
+
+	t.Run("skipAlternates", func(t *testing.T) {
+		for _, workers := range []int{0, 2} {
+			workers := workers
+			t.Run(fmt.Sprintf("workers%d", workers), func(t *testing.T) {
+				RegisterTestingT(t)
+
+				// The alternates of a skipped entry are not checked.
+				w := Writer{
+					ValidateUrls:    true,
+					SkipInvalid:     true,
+					CheckAlternates: true,
+					Workers:         workers,
+				}
+				var out bufferOuput
+				Ω(w.WriteAll(&out, &arrayInput{
+					Arr: []UrlEntry{
+						{Loc: "http://goiguide.com/en", Alternates: []Alternate{
+							{Hreflang: "fr", Href: "relative"},
+						}},
+						{Loc: "http://goiguide.com/fr"},
+					},
+					CustomUrlsetUrl: urlsetUrl,
+				})).Should(BeNil())
+				Ω(strings.Count(out.sitemaps[0].String(), "<url>")).Should(Equal(1))
+			})
+		}
+	})
+
+	t.Run("unknownHost", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		var skipped int
+		w := Writer{
+			ValidateUrls: true,
+			SkipInvalid:  true,
+			OnInvalid: func(err *EntryError) {
+				skipped++
+			},
+		}
+		var out bufferOuput
+		Ω(w.WriteAll(&out, &arrayInput{Arr: entries()})).Should(BeNil())
+		Ω(skipped).Should(Equal(3))
+		Ω(out.sitemaps[0].String()).Should(ContainSubstring("http://other.com/4"))
+	})
+
+	t.Run("outputHost", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		w := Writer{ValidateUrls: true}
+		out := NewDirOutput(t.TempDir())
+		out.BaseUrl = "http://goiguide.com/sitemaps/"
+		in := dynamicInput{
+			Size: 2,
+			CustomEntry: func(idx int) *UrlEntry {
+				return &UrlEntry{Loc: fmt.Sprintf("http://other.com/%d", idx)}
+			},
+		}
+		Ω(w.WriteAll(out, &in)).Should(MatchError(`entry "http://other.com/0" ` +
+			`has invalid loc: URL host "other.com" is not the sitemap host "goiguide.com"`))
+		Ω(out.Abort()).Should(BeNil())
+	})
+
+	t.Run("allSkippedWorkers", func(t *testing.T) {
+		RegisterTestingT(t)
+
+		w := Writer{
+			ValidateUrls: true,
+			SkipInvalid:  true,
+			Workers:      2,
+		}
+		in := dynamicInput{
+			Size: 50_000*2 + 1,
+			CustomEntry: func(idx int) *UrlEntry {
+				if idx < 50_000 || idx == 50_000*2 {
+					return &UrlEntry{Loc: "invalid"}
+				}
+				return &UrlEntry{Loc: fmt.Sprintf("http://goiguide.com/%d", idx)}
+			},
+		}
+		var out bufferOuput
+		Ω(w.WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(strings.Count(out.sitemaps[0].String(), "<url>")).Should(Equal(50_000))
+
+		in.CustomEntry = func(int) *UrlEntry {
+			return &UrlEntry{Loc: "invalid"}
+		}
+		in.Reset()
+		out = bufferOuput{}
+		Ω(w.WriteAll(&out, &in)).Should(BeNil())
+		Ω(out.sitemaps).Should(HaveLen(1))
+		Ω(out.sitemaps[0].String()).ShouldNot(ContainSubstring("<url>"))
+	})
+}